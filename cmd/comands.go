@@ -3,7 +3,10 @@ package cmd
 import (
 	"github.com/MakeNowJust/heredoc"
 
+	"github.com/Mryashbhardwaj/marketAnalysis/cmd/refresh"
 	"github.com/Mryashbhardwaj/marketAnalysis/cmd/server"
+	"github.com/Mryashbhardwaj/marketAnalysis/cmd/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/cmd/tradebook"
 	cli "github.com/spf13/cobra"
 )
 
@@ -17,7 +20,10 @@ func New() *cli.Command {
 		SilenceUsage: true,
 		Example: heredoc.Doc(`
 				$ marketWatch serve
-				$ marketWatch fetch-trends
+				$ marketWatch refresh-trends
+				$ marketWatch tradebook inspect
+				$ marketWatch tax export-112a --fy 2024-25
+				$ marketWatch tax harvest
 			`),
 		Annotations: map[string]string{
 			"group:core": "true",
@@ -31,6 +37,9 @@ func New() *cli.Command {
 	// Client related commands
 	cmd.AddCommand(
 		server.NewServeCommand(),
+		refresh.NewRefreshCommand(),
+		tradebook.NewTradebookCommand(),
+		tax.NewTaxCommand(),
 	)
 
 	return cmd
@@ -0,0 +1,176 @@
+package refresh
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/app"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/spf13/cobra"
+)
+
+type refreshCommand struct {
+	configFilePath string
+	equity         bool
+	mutualFunds    bool
+	symbols        []string
+	since          string
+
+	sinceTime time.Time
+	logger    *slog.Logger
+	config    *config.Config
+}
+
+// NewRefreshCommand initializes command to refresh the cached price history
+func NewRefreshCommand() *cobra.Command {
+	r := &refreshCommand{}
+
+	cmd := &cobra.Command{
+		Use:     "refresh-trends",
+		Aliases: []string{"fetch-trends"},
+		Short:   "Refresh the cached equity and mutual fund price history",
+		Example: "marketWatch refresh-trends -c /path/to/config.yaml --equity --symbol INFY --since 2024-01-01",
+		RunE:    r.RunE,
+		PreRunE: r.PreRunE,
+	}
+
+	// Config filepath flag
+	cmd.Flags().StringVarP(&r.configFilePath, "config", "c", "", "File path for client configuration")
+
+	cmd.Flags().BoolVar(&r.equity, "equity", false, "refresh equity price history")
+	cmd.Flags().BoolVar(&r.mutualFunds, "mf", false, "refresh mutual fund NAV history")
+	cmd.Flags().StringSliceVar(&r.symbols, "symbol", nil, "only refresh these tradebook equity symbols or mutual fund ISINs/names")
+	cmd.Flags().StringVar(&r.since, "since", "", "refetch history from this date (YYYY-MM-DD) instead of the last cached point")
+
+	return cmd
+}
+
+func (r *refreshCommand) PreRunE(_ *cobra.Command, _ []string) error {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+
+	r.logger = logger
+
+	if r.configFilePath == "" {
+		return errors.New("config file path is required")
+	}
+
+	if r.since != "" {
+		since, err := time.Parse(time.DateOnly, r.since)
+		if err != nil {
+			return fmt.Errorf("invalid --since %q, expected YYYY-MM-DD", r.since)
+		}
+		r.sinceTime = since
+	}
+
+	// refresh both asset classes unless one is asked for
+	if !r.equity && !r.mutualFunds {
+		r.equity = true
+		r.mutualFunds = true
+	}
+
+	cfg, err := config.LoadConfig(r.configFilePath)
+	if err != nil {
+		logger.Error("failed to open config file", slog.String("error", err.Error()))
+		return err
+	}
+
+	r.config = cfg
+
+	return nil
+}
+
+func (r *refreshCommand) RunE(_ *cobra.Command, _ []string) error {
+	a, err := app.New(r.config, r.logger)
+	if err != nil {
+		r.logger.Error("failed to set up", slog.String("error", err.Error()))
+		return err
+	}
+	tradebook := a.Tradebook
+
+	progress := func(done, total int, result service.RefreshResult) {
+		fmt.Printf("[%d/%d] %s %s\n", done, total, result.Symbol, result.Status)
+	}
+
+	allShares, allFunds, err := r.selectSymbols(tradebook.GetEquityList(), tradebook.GetMutualFundsList())
+	if err != nil {
+		return err
+	}
+
+	var reports []service.RefreshReport
+	if r.equity && len(allShares) > 0 {
+		reports = append(reports, a.EquityTrendCache.BuildPriceHistoryCacheSince(allShares, r.sinceTime, progress))
+	}
+
+	if r.mutualFunds && len(allFunds) > 0 {
+		reports = append(reports, a.MFTrendCache.BuildMFPriceHistoryCacheSince(allFunds, r.sinceTime, progress))
+	}
+
+	return printSummary(reports)
+}
+
+// selectSymbols narrows the tradebook to --symbol, each symbol is refreshed as a share or a fund
+// by where it is found in the tradebook, funds are matched by ISIN or name
+func (r *refreshCommand) selectSymbols(allShares []service.ScriptName, allFunds map[service.FundName]service.ISIN) ([]service.ScriptName, map[service.FundName]service.ISIN, error) {
+	if len(r.symbols) == 0 {
+		return allShares, allFunds, nil
+	}
+	var (
+		shares []service.ScriptName
+		funds  = make(map[service.FundName]service.ISIN)
+	)
+	for _, symbol := range r.symbols {
+		found := false
+		for _, share := range allShares {
+			if strings.EqualFold(symbol, share.String()) {
+				shares = append(shares, share)
+				found = true
+			}
+		}
+		for name, isin := range allFunds {
+			if strings.EqualFold(symbol, string(isin)) || strings.EqualFold(symbol, name.String()) {
+				funds[name] = isin
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("%s is neither an equity symbol nor a mutual fund in the tradebook", symbol)
+		}
+	}
+	if !r.equity && len(funds) == 0 {
+		return nil, nil, fmt.Errorf("no mutual fund in the tradebook matches %s", strings.Join(r.symbols, ", "))
+	}
+	if !r.mutualFunds && len(shares) == 0 {
+		return nil, nil, fmt.Errorf("no equity symbol in the tradebook matches %s", strings.Join(r.symbols, ", "))
+	}
+	return shares, funds, nil
+}
+
+func printSummary(reports []service.RefreshReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SYMBOL\tNAME\tSTATUS\tROWS ADDED\tDURATION\tERROR")
+
+	var failed int
+	for _, report := range reports {
+		for _, result := range report.Results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", result.Symbol, result.Name, result.Status, result.RowsAdded,
+				time.Duration(result.DurationMs)*time.Millisecond, result.Error)
+		}
+		failed += report.Failed
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to refresh %d symbols", failed)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -8,7 +9,10 @@ import (
 	"os"
 
 	"github.com/Mryashbhardwaj/marketAnalysis/core/api/routes"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/app"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/jobs"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/scheduler"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/handlers"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
 	"github.com/spf13/cobra"
@@ -66,22 +70,57 @@ func (s *serveCommand) PreRunE(cmd *cobra.Command, _ []string) error {
 
 func (s *serveCommand) RunE(_ *cobra.Command, _ []string) error {
 
-	//  get tradebook
-
-	tradebook, err := service.GetTradebookService(
-		s.config.Equity.TradeFilesDirectory,
-		s.config.MutualFunds.TradeFilesDirectory,
-		s.logger)
-
+	a, err := app.New(s.config, s.logger)
 	if err != nil {
-		s.logger.Error("failed to get tradebook service", slog.String("error", err.Error()))
+		s.logger.Error("failed to set up", slog.String("error", err.Error()))
 		return err
 	}
+	tradebook, equityTrendCache, mfTrendCache := a.Tradebook, a.EquityTrendCache, a.MFTrendCache
+
+	if bhavcopies, ok := a.Providers.Registry.Get("bhavcopy"); ok {
+		err = equityTrendCache.ImportPriceHistory(bhavcopies, tradebook.GetEquityList())
+		if err != nil {
+			s.logger.Error("failed to import bhavcopies", slog.String("error", err.Error()))
+		}
+	}
 
-	mfTrendCache := service.GetMFTrendCache(s.logger, tradebook.MutualFundsTradebookCache.ISINToFundName)
-	equityTrendCache := service.GetEquityTrendCache(s.logger, tradebook.EquityTradebookCache.AllShares)
+	jobManager := jobs.NewManager(s.logger)
+	jobManager.Register(jobs.KindEquity, func(progress service.RefreshProgress) service.RefreshReport {
+		return equityTrendCache.BuildPriceHistoryCache(tradebook.GetEquityList(), progress)
+	})
+	jobManager.Register(jobs.KindMutualFunds, func(progress service.RefreshProgress) service.RefreshReport {
+		return mfTrendCache.BuildMFPriceHistoryCache(tradebook.GetMutualFundsList(), progress)
+	})
+
+	var refreshScheduler handlers.Scheduler
+	if s.config.Scheduler.Enabled {
+		sched, err := scheduler.FromConfig(s.config.Scheduler, s.logger, jobManager)
+		if err != nil {
+			s.logger.Error("failed to set up scheduler", slog.String("error", err.Error()))
+			return err
+		}
+		go sched.Run(context.Background())
+		refreshScheduler = sched
+	}
+
+	if s.config.Tradebook.ReloadInterval > 0 {
+		go tradebook.Watch(context.Background(), s.config.Tradebook.ReloadInterval, func(changes service.TradebookChanges) {
+			mfTrendCache.SetFundNames(tradebook.GetMutualFundsTradebook().ISINToFundName)
+			// fetch the history of symbols and funds seen for the first time
+			if len(changes.NewShares) > 0 {
+				if _, _, err := jobManager.Submit(jobs.KindEquity); err != nil {
+					s.logger.Error("failed to submit equity refresh", slog.String("error", err.Error()))
+				}
+			}
+			if len(changes.NewFunds) > 0 {
+				if _, _, err := jobManager.Submit(jobs.KindMutualFunds); err != nil {
+					s.logger.Error("failed to submit mutual funds refresh", slog.String("error", err.Error()))
+				}
+			}
+		})
+	}
 
-	handlers := handlers.GetHandler(tradebook, equityTrendCache, mfTrendCache)
+	handlers := handlers.GetHandler(tradebook, equityTrendCache, mfTrendCache, jobManager, refreshScheduler, a.Tax)
 
 	router := routes.SetupRouter(handlers)
 	//  todo: take handlers as new handler and inject logger in handlers.SetupRouter
@@ -0,0 +1,73 @@
+package tax
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type harvestCommand struct {
+	taxCommand
+}
+
+func newHarvestCommand() *cobra.Command {
+	h := &harvestCommand{}
+
+	cmd := &cobra.Command{
+		Use:     "harvest",
+		Short:   "Suggest sales that book losses, or tax free gains, before the financial year ends",
+		Example: "marketWatch tax harvest -c /path/to/config.yaml",
+		RunE:    h.RunE,
+		PreRunE: h.PreRunE,
+	}
+
+	h.addFlags(cmd)
+
+	return cmd
+}
+
+func (h *harvestCommand) RunE(cmd *cobra.Command, _ []string) error {
+	tradebook, calculator, err := h.setup()
+	if err != nil {
+		return err
+	}
+	report := calculator.Harvest(tradebook, h.account, time.Now().In(utils.IST))
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "FY %s, sell by %s\n", report.FinancialYear, report.Deadline.Format(time.DateOnly))
+	fmt.Fprintf(out, "Realised short-term %.2f, long-term %.2f, estimated tax %.2f\n\n",
+		report.ShortTermGain, report.LongTermGain, report.EstimatedTax)
+
+	fmt.Fprintf(out, "Losses to book, saving %.2f of tax together\n", report.TaxSaved)
+	if err := printSuggestions(out, report.Losses, true); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nGains to book within the %.2f of the %.2f exemption left\n", report.ExemptionRemaining, report.ExemptionLimit)
+	if err := printSuggestions(out, report.Gains, false); err != nil {
+		return err
+	}
+	if len(report.Unpriced) > 0 {
+		fmt.Fprintf(out, "\nno cached price, refresh the price history: %s\n", strings.Join(report.Unpriced, ", "))
+	}
+	return nil
+}
+
+func printSuggestions(out io.Writer, suggestions []tax.HarvestSuggestion, taxSaved bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tSYMBOL\tUNITS\tPRICE\tCOST\tVALUE\tSHORT-TERM\tLONG-TERM\tTAX SAVED")
+	for _, s := range suggestions {
+		saved := "-"
+		if taxSaved {
+			saved = fmt.Sprintf("%.2f", s.TaxSaved)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%g\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%s\n", s.Account, s.Symbol, s.Units, s.Price,
+			s.Cost, s.MarketValue, s.ShortTermGain, s.LongTermGain, saved)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,138 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/app"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// NewTaxCommand initializes commands reporting capital gains tax
+func NewTaxCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tax <subcommand>",
+		Short: "Report capital gains tax from the tradebook",
+	}
+	cmd.AddCommand(newExport112ACommand(), newHarvestCommand())
+	return cmd
+}
+
+// taxCommand holds the flags and setup shared by the tax commands
+type taxCommand struct {
+	configFilePath string
+	account        string
+
+	logger *slog.Logger
+	config *config.Config
+}
+
+func (t *taxCommand) addFlags(cmd *cobra.Command) {
+	// Config filepath flag
+	cmd.Flags().StringVarP(&t.configFilePath, "config", "c", "", "File path for client configuration")
+	cmd.Flags().StringVar(&t.account, "account", service.AllAccounts, "account to report, all reports every account")
+}
+
+func (t *taxCommand) PreRunE(_ *cobra.Command, _ []string) error {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	t.logger = logger
+
+	if t.configFilePath == "" {
+		return errors.New("config file path is required")
+	}
+
+	cfg, err := config.LoadConfig(t.configFilePath)
+	if err != nil {
+		logger.Error("failed to open config file", slog.String("error", err.Error()))
+		return err
+	}
+
+	t.config = cfg
+
+	return nil
+}
+
+// setup reads the tradebook of the account and builds a calculator over the cached price history
+func (t *taxCommand) setup() (*service.TradebookService, *tax.Calculator, error) {
+	a, err := app.New(t.config, t.logger)
+	if err != nil {
+		t.logger.Error("failed to set up", slog.String("error", err.Error()))
+		return nil, nil, err
+	}
+	account, err := a.Tradebook.Account(t.account)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, a.Tax, nil
+}
+
+type export112ACommand struct {
+	taxCommand
+	fy     string
+	output string
+}
+
+func newExport112ACommand() *cobra.Command {
+	e := &export112ACommand{}
+
+	cmd := &cobra.Command{
+		Use:     "export-112a",
+		Short:   "Export the long-term gains on shares and equity funds as a Schedule 112A CSV",
+		Example: "marketWatch tax export-112a --fy 2024-25 -c /path/to/config.yaml",
+		RunE:    e.RunE,
+		PreRunE: e.PreRunE,
+	}
+
+	e.addFlags(cmd)
+	cmd.Flags().StringVar(&e.fy, "fy", utils.FinancialYear(time.Now().In(utils.IST)), "financial year, e.g. 2024-25")
+	cmd.Flags().StringVarP(&e.output, "output", "o", "", "file to write, defaults to schedule_112a_<fy>.csv, - writes to stdout")
+
+	return cmd
+}
+
+func (e *export112ACommand) RunE(cmd *cobra.Command, _ []string) error {
+	tradebook, calculator, err := e.setup()
+	if err != nil {
+		return err
+	}
+	rows, err := calculator.Schedule112A(tradebook, e.account, e.fy)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = cmd.OutOrStdout()
+	if e.output != "-" {
+		if e.output == "" {
+			e.output = fmt.Sprintf("schedule_112a_%s.csv", e.fy)
+		}
+		file, err := os.Create(e.output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := tax.WriteSchedule112A(out, rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.Warning != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s: %s\n", row.Name, row.Warning)
+		}
+	}
+	if e.output != "-" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "wrote %d rows to %s\n", len(rows), e.output)
+	}
+	return nil
+}
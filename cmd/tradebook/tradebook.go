@@ -0,0 +1,162 @@
+package tradebook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/spf13/cobra"
+)
+
+// NewTradebookCommand initializes commands working with the tradebook files
+func NewTradebookCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tradebook <subcommand>",
+		Short: "Inspect the tradebook files",
+	}
+	cmd.AddCommand(newInspectCommand())
+	return cmd
+}
+
+type inspectCommand struct {
+	configFilePath string
+
+	logger *slog.Logger
+	config *config.Config
+}
+
+func newInspectCommand() *cobra.Command {
+	i := &inspectCommand{}
+
+	cmd := &cobra.Command{
+		Use:     "inspect",
+		Short:   "Report how the tradebook files were read",
+		Example: "marketWatch tradebook inspect -c /path/to/config.yaml",
+		RunE:    i.RunE,
+		PreRunE: i.PreRunE,
+	}
+
+	// Config filepath flag
+	cmd.Flags().StringVarP(&i.configFilePath, "config", "c", "", "File path for client configuration")
+
+	return cmd
+}
+
+func (i *inspectCommand) PreRunE(_ *cobra.Command, _ []string) error {
+	// row errors are part of the report, only log failures
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelError,
+	}))
+
+	i.logger = logger
+
+	if i.configFilePath == "" {
+		return errors.New("config file path is required")
+	}
+
+	cfg, err := config.LoadConfig(i.configFilePath)
+	if err != nil {
+		logger.Error("failed to open config file", slog.String("error", err.Error()))
+		return err
+	}
+
+	i.config = cfg
+
+	return nil
+}
+
+func (i *inspectCommand) RunE(cmd *cobra.Command, _ []string) error {
+	tradebook, err := service.GetTradebookService(i.config.GetAccounts(), i.logger)
+	if err != nil {
+		i.logger.Error("failed to get tradebook service", slog.String("error", err.Error()))
+		return err
+	}
+
+	report := tradebook.GetIngestionReport()
+	out := cmd.OutOrStdout()
+	accounts := i.config.GetAccounts()
+	for n, account := range accounts {
+		ingestion := report.Accounts[n]
+		if len(accounts) > 1 {
+			fmt.Fprintf(out, "Account %s", account.Name)
+			if account.Owner != "" {
+				fmt.Fprintf(out, " (%s)", account.Owner)
+			}
+			fmt.Fprint(out, "\n\n")
+		}
+		if account.EquityTradeFilesDirectory != "" {
+			if err := printSegment(out, "Equity", ingestion.Equity); err != nil {
+				return err
+			}
+		}
+		if account.MutualFundsTradeFilesDirectory != "" {
+			if err := printSegment(out, "Mutual funds", ingestion.MutualFunds); err != nil {
+				return err
+			}
+		}
+		if account.DividendsDirectory != "" {
+			printDividendFiles(out, ingestion.DividendFiles)
+		}
+	}
+	return nil
+}
+
+func printSegment(out io.Writer, title string, segment service.SegmentIngestion) error {
+	fmt.Fprintf(out, "%s: %d trades from %d files", title, segment.Trades, len(segment.Files))
+	if segment.Trades > 0 {
+		fmt.Fprintf(out, ", %s to %s", formatDate(segment.From), formatDate(segment.To))
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tBROKER\tTRADES\tDUPLICATES\tHEADERS\tIGNORED\tERRORS\tFROM\tTO")
+	for _, file := range segment.Files {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", file.Name, file.Broker, file.Rows, file.Duplicates,
+			file.HeaderRows, file.IgnoredRows, len(file.Errors), formatDate(file.From), formatDate(file.To))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, file := range segment.Files {
+		for _, rowErr := range file.Errors {
+			fmt.Fprintf(out, "error: %s\n", rowErr.Error())
+		}
+	}
+	for _, duplicate := range segment.Duplicates {
+		fmt.Fprintf(out, "duplicate %s trade %s in %s\n", duplicate.Broker, duplicate.TradeID, strings.Join(duplicate.Files, ", "))
+	}
+	if len(segment.Gaps) > 0 {
+		fmt.Fprintf(out, "months without a file: %s\n", strings.Join(segment.Gaps, ", "))
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+func printDividendFiles(out io.Writer, files []service.TradeFile) {
+	var dividends int
+	for _, file := range files {
+		dividends += file.Rows
+	}
+	fmt.Fprintf(out, "Dividends: %d from %d files\n", dividends, len(files))
+	for _, file := range files {
+		for _, rowErr := range file.Errors {
+			fmt.Fprintf(out, "error: %s\n", rowErr.Error())
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.DateOnly)
+}
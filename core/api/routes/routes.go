@@ -13,15 +13,28 @@ func SetupRouter(handler *handlers.Handler) *mux.Router {
 	router.HandleFunc("/api/equity/list", handler.GetEquityList).Methods("GET")
 	router.HandleFunc("/api/equity/trend", handler.GetTrend).Methods("GET")
 	router.HandleFunc("/api/equity/trend/compare", handler.GetTrendComparison).Methods("GET")
-	router.HandleFunc("/api/equity/history/refresh", handler.RefreshPriceHistory).Methods("GET")
+	router.HandleFunc("/api/equity/history/refresh", handler.RefreshPriceHistory).Methods("POST")
 	router.HandleFunc("/api/equity/breakdown", handler.GetEqBreakdown).Methods("GET")
+	router.HandleFunc("/api/equity/pnl", handler.GetEquityPnL).Methods("GET")
 
 	router.HandleFunc("/api/mutual_funds/list", handler.GetMutualFundsList).Methods("GET")
 	router.HandleFunc("/api/mutual_funds/positions", handler.GetMFPositions).Methods("GET")
 	router.HandleFunc("/api/mutual_funds/trend", handler.GetMFTrend).Methods("GET")
 	router.HandleFunc("/api/mutual_funds/summary", handler.GetMFSummary).Methods("GET")
 	router.HandleFunc("/api/mutual_funds/trend/compare", handler.GetMFGrowthComparison).Methods("GET")
-	router.HandleFunc("/api/mutual_funds/history/refresh", handler.RefreshMFPriceHistory).Methods("GET")
+	router.HandleFunc("/api/mutual_funds/history/refresh", handler.RefreshMFPriceHistory).Methods("POST")
+
+	router.HandleFunc("/api/dividends", handler.GetDividends).Methods("GET")
+	router.HandleFunc("/api/tax/capital-gains", handler.GetCapitalGains).Methods("GET")
+	router.HandleFunc("/api/tax/schedule-112a", handler.GetSchedule112A).Methods("GET")
+	router.HandleFunc("/api/tax/harvest", handler.GetHarvest).Methods("GET")
+
+	router.HandleFunc("/api/jobs", handler.ListJobs).Methods("GET")
+	router.HandleFunc("/api/jobs/{id}", handler.GetJob).Methods("GET")
+	router.HandleFunc("/api/scheduler", handler.GetSchedulerStatus).Methods("GET")
+	router.HandleFunc("/api/accounts", handler.GetAccounts).Methods("GET")
+	router.HandleFunc("/api/tradebook/status", handler.GetTradebookStatus).Methods("GET")
+	router.HandleFunc("/api/tradebook/ingestion", handler.GetIngestionReport).Methods("GET")
 
 	return router
 }
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"log/slog"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/pkg/errors"
+)
+
+// App is the tradebook, price history and tax calculator the commands share,
+// wired with the cost basis, charges, symbol lineage and corporate actions of the config
+type App struct {
+	Tradebook        *service.TradebookService
+	Providers        *service.PriceProviders
+	EquityTrendCache *service.EquityTrendCache
+	MFTrendCache     *service.MFTrendCache
+	Tax              *tax.Calculator
+}
+
+// New reads the tradebooks of cfg and the cached price history, nothing is fetched
+func New(cfg *config.Config, logger *slog.Logger) (*App, error) {
+	tradebook, err := service.GetTradebookService(cfg.GetAccounts(), logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tradebook service")
+	}
+
+	if err := tradebook.SetCostBasis(cfg.Equity.CostBasis); err != nil {
+		return nil, errors.Wrap(err, "invalid equity cost basis")
+	}
+
+	chargeRates, err := service.NewChargeRates(cfg.Equity.Charges)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid equity charges")
+	}
+	tradebook.SetChargeRates(chargeRates)
+
+	var lineages *service.SymbolLineages
+	if cfg.Equity.SymbolLineageFile != "" {
+		lineages, err = service.LoadSymbolLineages(cfg.Equity.SymbolLineageFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load symbol lineage")
+		}
+		tradebook.SetSymbolLineages(lineages)
+	}
+
+	providers, err := service.GetPriceProviders(cfg, tradebook.GetMutualFundsTradebook().ISINToFundName, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up price providers")
+	}
+
+	mfTrendCache := service.GetMFTrendCache(logger, tradebook.GetMutualFundsTradebook().ISINToFundName, providers.MutualFunds, cfg.TrendsDirectory, cfg.Refresh.Concurrency)
+	equityTrendCache := service.GetEquityTrendCache(logger, tradebook.GetEquityList(), providers.Equity, cfg.TrendsDirectory, cfg.Refresh.Concurrency)
+	equityTrendCache.SetSymbolLineages(lineages)
+
+	var actions *service.CorporateActions
+	if cfg.Equity.CorporateActionsFile != "" {
+		actions, err = service.LoadCorporateActions(cfg.Equity.CorporateActionsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load corporate actions")
+		}
+		tradebook.SetCorporateActions(actions)
+		equityTrendCache.SetCorporateActions(actions)
+	}
+
+	calculator, err := tax.FromConfig(cfg.Tax)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up tax calculator")
+	}
+	calculator.SetPriceHistory(equityTrendCache, mfTrendCache)
+	calculator.SetCorporateActions(actions)
+
+	return &App{
+		Tradebook:        tradebook,
+		Providers:        providers,
+		EquityTrendCache: equityTrendCache,
+		MFTrendCache:     mfTrendCache,
+		Tax:              calculator,
+	}, nil
+}
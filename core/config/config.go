@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,14 +12,147 @@ import (
 type Config struct {
 	MutualFunds MutualFundConfig `yaml:"mutual_funds"`
 	Equity      EquityConfig     `yaml:"equity"`
+	// TrendsDirectory is where fetched price history is cached, defaults to ./data/trends
+	TrendsDirectory string          `yaml:"trends_directory"`
+	Refresh         RefreshConfig   `yaml:"refresh"`
+	Scheduler       SchedulerConfig `yaml:"scheduler"`
+	Tradebook       TradebookConfig `yaml:"tradebook"`
+	// Accounts are the demat accounts tracked together, e.g. of a household. When
+	// none are set the tradefile directories of equity and mutual_funds are used.
+	Accounts []AccountConfig `yaml:"accounts"`
+	// DividendsDirectory holds dividend statements and ledgers of the default account
+	DividendsDirectory string    `yaml:"dividends_directory"`
+	Tax                TaxConfig `yaml:"tax"`
 }
 
+// TaxConfig sets up the capital gains tax computed from closed lots
+type TaxConfig struct {
+	// SlabRate is the income tax slab rate in percent applied to short-term gains of
+	// debt and other funds, defaults to 30
+	SlabRate float64 `yaml:"slab_rate"`
+	// LTCGExemption overrides the long-term gains exempt under section 112A by financial
+	// year, e.g. "2024-25": 125000
+	LTCGExemption map[string]float64 `yaml:"ltcg_exemption"`
+	// FundTypes classifies funds by ISIN as equity (default), debt or other
+	FundTypes map[string]string `yaml:"fund_types"`
+	// FMVFile lists the fair market value on 31 January 2018 by isin or symbol in YAML or CSV,
+	// it overrides the value looked up from the price history
+	FMVFile string `yaml:"fmv_file"`
+}
+
+// AccountConfig is a demat account with its own tradefile directories
+type AccountConfig struct {
+	Name string `yaml:"name"`
+	// Owner is who holds the account, e.g. self or spouse
+	Owner                          string `yaml:"owner"`
+	EquityTradeFilesDirectory      string `yaml:"equity_tradefiles_directory"`
+	MutualFundsTradeFilesDirectory string `yaml:"mutual_funds_tradefiles_directory"`
+	// DividendsDirectory holds Zerodha dividend statements (CSV) and dividend ledgers (YAML)
+	DividendsDirectory string `yaml:"dividends_directory"`
+}
+
+// DefaultAccount names the account read from the tradefile directories of equity and mutual_funds
+const DefaultAccount = "default"
+
+// TradebookConfig controls how the tradebook is kept up to date while serving
+type TradebookConfig struct {
+	// ReloadInterval is how often the tradefile directories are checked for changes,
+	// e.g. 30s. Reloading is disabled when unset.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// RefreshConfig controls how price history refreshes call upstream providers
+type RefreshConfig struct {
+	// Concurrency is the number of symbols fetched in parallel
+	Concurrency int `yaml:"concurrency"`
+	// RequestsPerSecond is the request rate allowed per upstream host
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	// MaxRetries of a rate limited or failed request, a negative value disables retries
+	MaxRetries int `yaml:"max_retries"`
+}
+
+// SchedulerConfig sets up periodic price history refreshes while serving
+type SchedulerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Timezone of the schedule times, defaults to IST
+	Timezone string `yaml:"timezone"`
+	// Holidays are exchange holidays in YYYY-MM-DD on which trading day schedules are skipped
+	Holidays []string `yaml:"holidays"`
+	// HolidaysFile lists more holidays, one YYYY-MM-DD date per line
+	HolidaysFile string           `yaml:"holidays_file"`
+	Schedules    []ScheduleConfig `yaml:"schedules"`
+}
+
+type ScheduleConfig struct {
+	// Job is the refresh to run, equity or mutual_funds
+	Job string `yaml:"job"`
+	// At is the time of day in HH:MM
+	At string `yaml:"at"`
+	// Days is either trading (default), skipping weekends and holidays, or all
+	Days string `yaml:"days"`
+}
+
+const (
+	defaultTrendsDirectory   = "./data/trends"
+	defaultConcurrency       = 4
+	defaultRequestsPerSecond = 2
+	defaultMaxRetries        = 3
+)
+
 type MutualFundConfig struct {
 	TradeFilesDirectory string `yaml:"tradefiles_diretory"`
+	// PriceProviders lists NAV sources in order of preference, the next one is tried when one fails
+	PriceProviders []string `yaml:"price_providers"`
+	// AMFINAVDirectory holds downloaded AMFI NAV files, enables the "amfi" price provider
+	AMFINAVDirectory string `yaml:"amfi_nav_directory"`
 }
 
 type EquityConfig struct {
 	TradeFilesDirectory string `yaml:"tradefiles_diretory"`
+	// PriceProviders lists OHLCV sources in order of preference, the next one is tried when one fails
+	PriceProviders []string `yaml:"price_providers"`
+	// BhavcopyDirectory holds daily NSE/BSE bhavcopy CSVs, enables the "bhavcopy" price provider
+	BhavcopyDirectory string `yaml:"bhavcopy_directory"`
+	// CorporateActionsFile lists splits and bonuses in YAML or CSV, trades and candles
+	// before an ex-date are adjusted to the share count after it
+	CorporateActionsFile string `yaml:"corporate_actions_file"`
+	// SymbolLineageFile lists renames, mergers and demergers in YAML or CSV, the trades of
+	// an old symbol are consolidated under its successor
+	SymbolLineageFile string `yaml:"symbol_lineage_file"`
+	// CostBasis matches sells with buys, fifo (default) or average
+	CostBasis string `yaml:"cost_basis"`
+	// Charges are versions of the brokerage and statutory charges on trades, each effective
+	// from its day until the next version. Trades are free of charges when unset.
+	Charges []ChargesConfig `yaml:"charges"`
+}
+
+// ChargesConfig is a version of the rates charged on equity trades
+type ChargesConfig struct {
+	// EffectiveFrom is the first day, in YYYY-MM-DD, the rates apply to
+	EffectiveFrom string              `yaml:"effective_from"`
+	Rates         []ChargeRatesConfig `yaml:"rates"`
+}
+
+// ChargeRatesConfig are the rates of the trades of an exchange, segment and product, percentages
+// are of the trade value
+type ChargeRatesConfig struct {
+	// Exchange, e.g. NSE, Segment, e.g. EQ, and Product select the trades, unset matches any.
+	// Product is delivery or intraday, trades of a day with both buys and sells are intraday.
+	Exchange string `yaml:"exchange"`
+	Segment  string `yaml:"segment"`
+	Product  string `yaml:"product"`
+	// BrokerageMax caps the brokerage of an order, unset leaves it uncapped
+	BrokeragePercent float64 `yaml:"brokerage_percent"`
+	BrokerageMax     float64 `yaml:"brokerage_max"`
+	STTBuyPercent    float64 `yaml:"stt_buy_percent"`
+	STTSellPercent   float64 `yaml:"stt_sell_percent"`
+	// ExchangePercent is the exchange transaction charge
+	ExchangePercent float64 `yaml:"exchange_percent"`
+	// SEBIPerCrore is the SEBI turnover fee per crore of trade value
+	SEBIPerCrore        float64 `yaml:"sebi_per_crore"`
+	StampDutyBuyPercent float64 `yaml:"stamp_duty_buy_percent"`
+	// GSTPercent is levied on the brokerage, exchange charges and SEBI fees
+	GSTPercent float64 `yaml:"gst_percent"`
 }
 
 // LoadConfig reads and parses the YAML config file
@@ -35,6 +169,31 @@ func LoadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	if cfg.TrendsDirectory == "" {
+		cfg.TrendsDirectory = defaultTrendsDirectory
+	}
+	if cfg.Refresh.Concurrency <= 0 {
+		cfg.Refresh.Concurrency = defaultConcurrency
+	}
+	if cfg.Refresh.RequestsPerSecond <= 0 {
+		cfg.Refresh.RequestsPerSecond = defaultRequestsPerSecond
+	}
+	if cfg.Refresh.MaxRetries == 0 {
+		cfg.Refresh.MaxRetries = defaultMaxRetries
+	}
 
 	return &cfg, nil
 }
+
+// GetAccounts returns the configured accounts, or the default account when none are set
+func (c *Config) GetAccounts() []AccountConfig {
+	if len(c.Accounts) > 0 {
+		return c.Accounts
+	}
+	return []AccountConfig{{
+		Name:                           DefaultAccount,
+		EquityTradeFilesDirectory:      c.Equity.TradeFilesDirectory,
+		MutualFundsTradeFilesDirectory: c.MutualFunds.TradeFilesDirectory,
+		DividendsDirectory:             c.DividendsDirectory,
+	}}
+}
@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/pkg/errors"
+)
+
+const (
+	KindEquity      = "equity"
+	KindMutualFunds = "mutual_funds"
+
+	// recentJobsLimit is the number of finished jobs kept for the job status API
+	recentJobsLimit = 50
+)
+
+type State string
+
+const (
+	StateQueued          State = "queued"
+	StateRunning         State = "running"
+	StateSucceeded       State = "succeeded"
+	StatePartiallyFailed State = "partially_failed"
+	StateFailed          State = "failed"
+)
+
+// RunFunc performs a refresh, reporting every finished symbol through progress
+type RunFunc func(progress service.RefreshProgress) service.RefreshReport
+
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Job is a point in time view of a refresh job
+type Job struct {
+	ID         string                  `json:"id"`
+	Kind       string                  `json:"kind"`
+	State      State                   `json:"state"`
+	CreatedAt  time.Time               `json:"created_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Progress   Progress                `json:"progress"`
+	Results    []service.RefreshResult `json:"results"`
+}
+
+// Manager runs refresh jobs in the background, at most one per kind at a time
+type Manager struct {
+	logger  *slog.Logger
+	runners map[string]RunFunc
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	running map[string]string
+}
+
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{
+		logger:  logger,
+		runners: make(map[string]RunFunc),
+		jobs:    make(map[string]*Job),
+		running: make(map[string]string),
+	}
+}
+
+// Register sets the refresh performed for jobs of kind, it is meant to be called while wiring up
+func (m *Manager) Register(kind string, run RunFunc) {
+	m.runners[kind] = run
+}
+
+// Submit starts a job of kind in the background. When one is already queued or
+// running for kind that job is returned instead and attached is true.
+func (m *Manager) Submit(kind string) (job Job, attached bool, err error) {
+	run, ok := m.runners[kind]
+	if !ok {
+		return Job{}, false, errors.Errorf("unknown job kind %q", kind)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.running[kind]; ok {
+		return m.jobs[id].snapshot(), true, nil
+	}
+
+	j := &Job{
+		ID:        newID(),
+		Kind:      kind,
+		State:     StateQueued,
+		CreatedAt: time.Now(),
+	}
+	m.jobs[j.ID] = j
+	m.order = append(m.order, j.ID)
+	m.running[kind] = j.ID
+	m.evict()
+
+	go m.run(j, run)
+
+	return j.snapshot(), false, nil
+}
+
+func (m *Manager) run(j *Job, run RunFunc) {
+	m.mu.Lock()
+	now := time.Now()
+	j.StartedAt = &now
+	j.State = StateRunning
+	m.mu.Unlock()
+
+	m.logger.Info("refresh job started", slog.String("job_id", j.ID), slog.String("kind", j.Kind))
+
+	report := run(func(done, total int, result service.RefreshResult) {
+		m.mu.Lock()
+		j.Progress = Progress{Done: done, Total: total}
+		j.Results = append(j.Results, result)
+		m.mu.Unlock()
+
+		attrs := []any{
+			slog.String("job_id", j.ID),
+			slog.String("symbol", result.Symbol),
+			slog.String("status", string(result.Status)),
+			slog.Int("rows_added", result.RowsAdded),
+			slog.Int64("duration_ms", result.DurationMs),
+			slog.Int("done", done),
+			slog.Int("total", total),
+		}
+		if result.Status == service.RefreshFailed {
+			m.logger.Error("price history refresh failed", append(attrs, slog.String("error", result.Error))...)
+			return
+		}
+		m.logger.Info("price history refreshed", attrs...)
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := time.Now()
+	j.FinishedAt = &finished
+	j.Progress = Progress{Done: len(report.Results), Total: report.Total}
+	j.Results = report.Results
+	switch {
+	case report.Failed == 0:
+		j.State = StateSucceeded
+	case report.Failed == report.Total:
+		j.State = StateFailed
+	default:
+		j.State = StatePartiallyFailed
+	}
+	delete(m.running, j.Kind)
+
+	m.logger.Info("refresh job finished", slog.String("job_id", j.ID), slog.String("state", string(j.State)),
+		slog.Int("ok", report.OK), slog.Int("failed", report.Failed), slog.Int("skipped", report.Skipped))
+}
+
+// Get returns the job with id
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot(), true
+}
+
+// List returns the recent jobs, newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Job, 0, len(m.order))
+	for i := len(m.order) - 1; i >= 0; i-- {
+		list = append(list, m.jobs[m.order[i]].snapshot())
+	}
+	return list
+}
+
+// evict drops the oldest finished jobs beyond recentJobsLimit, must be called with mu held
+func (m *Manager) evict() {
+	for len(m.order) > recentJobsLimit {
+		evicted := false
+		for i, id := range m.order {
+			if m.jobs[id].FinishedAt == nil {
+				continue
+			}
+			delete(m.jobs, id)
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			evicted = true
+			break
+		}
+		if !evicted {
+			return
+		}
+	}
+}
+
+func (j *Job) snapshot() Job {
+	s := *j
+	s.Results = append([]service.RefreshResult(nil), j.Results...)
+	return s
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
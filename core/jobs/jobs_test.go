@@ -0,0 +1,79 @@
+package jobs_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/jobs"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func waitForState(t *testing.T, m *jobs.Manager, id string, state jobs.State) jobs.Job {
+	var job jobs.Job
+	assert.Eventually(t, func() bool {
+		job, _ = m.Get(id)
+		return job.State == state
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestManager(t *testing.T) {
+	t.Run("attaches duplicate requests to the running job", func(t *testing.T) {
+		release := make(chan struct{})
+		m := jobs.NewManager(slog.Default())
+		m.Register(jobs.KindEquity, func(progress service.RefreshProgress) service.RefreshReport {
+			<-release
+			result := service.RefreshResult{Symbol: "INFY", Status: service.RefreshOK, RowsAdded: 1}
+			progress(1, 1, result)
+			return service.RefreshReport{Total: 1, OK: 1, Results: []service.RefreshResult{result}}
+		})
+
+		first, attached, err := m.Submit(jobs.KindEquity)
+		assert.Nil(t, err)
+		assert.False(t, attached)
+
+		second, attached, err := m.Submit(jobs.KindEquity)
+		assert.Nil(t, err)
+		assert.True(t, attached)
+		assert.Equal(t, first.ID, second.ID)
+
+		close(release)
+		job := waitForState(t, m, first.ID, jobs.StateSucceeded)
+		assert.Equal(t, jobs.Progress{Done: 1, Total: 1}, job.Progress)
+		assert.Len(t, job.Results, 1)
+		assert.NotNil(t, job.FinishedAt)
+
+		third, attached, err := m.Submit(jobs.KindEquity)
+		assert.Nil(t, err)
+		assert.False(t, attached)
+		assert.NotEqual(t, first.ID, third.ID)
+		waitForState(t, m, third.ID, jobs.StateSucceeded)
+
+		list := m.List()
+		assert.Len(t, list, 2)
+		assert.Equal(t, third.ID, list[0].ID)
+	})
+
+	t.Run("marks jobs with failed symbols", func(t *testing.T) {
+		m := jobs.NewManager(slog.Default())
+		m.Register(jobs.KindMutualFunds, func(_ service.RefreshProgress) service.RefreshReport {
+			return service.RefreshReport{Total: 2, OK: 1, Failed: 1, Results: []service.RefreshResult{
+				{Symbol: "INF179K01UT0", Status: service.RefreshOK},
+				{Symbol: "INF000000000", Status: service.RefreshFailed, Error: "upstream down"},
+			}}
+		})
+
+		job, _, err := m.Submit(jobs.KindMutualFunds)
+		assert.Nil(t, err)
+		job = waitForState(t, m, job.ID, jobs.StatePartiallyFailed)
+		assert.Equal(t, "upstream down", job.Results[1].Error)
+	})
+
+	t.Run("rejects unknown kinds", func(t *testing.T) {
+		_, _, err := jobs.NewManager(slog.Default()).Submit("bonds")
+		assert.ErrorContains(t, err, "unknown job kind")
+	})
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Calendar knows which days the exchanges are open
+type Calendar struct {
+	holidays map[string]struct{}
+}
+
+// NewCalendar creates a calendar closed on weekends and the given holidays,
+// holidays are dates in YYYY-MM-DD
+func NewCalendar(holidays []string) (*Calendar, error) {
+	c := &Calendar{holidays: make(map[string]struct{})}
+	for _, h := range holidays {
+		h = strings.TrimSpace(h)
+		if _, err := time.Parse(time.DateOnly, h); err != nil {
+			return nil, errors.Errorf("invalid holiday %q, expected YYYY-MM-DD", h)
+		}
+		c.holidays[h] = struct{}{}
+	}
+	return c, nil
+}
+
+// ReadHolidays reads one YYYY-MM-DD date per line, blank lines and lines starting with # are ignored
+func ReadHolidays(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read holidays file")
+	}
+	defer file.Close()
+
+	var holidays []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		holidays = append(holidays, line)
+	}
+	return holidays, scanner.Err()
+}
+
+// IsTradingDay reports whether the exchanges are open on the day of t, in the location of t
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	switch t.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	_, holiday := c.holidays[t.Format(time.DateOnly)]
+	return !holiday
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/jobs"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// FromConfig builds a scheduler for the configured schedules
+func FromConfig(cfg config.SchedulerConfig, logger *slog.Logger, jobRunner JobRunner) (*Scheduler, error) {
+	location := utils.IST
+	if cfg.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid scheduler timezone %q", cfg.Timezone)
+		}
+	}
+
+	holidays := cfg.Holidays
+	if cfg.HolidaysFile != "" {
+		fromFile, err := ReadHolidays(cfg.HolidaysFile)
+		if err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, fromFile...)
+	}
+	calendar, err := NewCalendar(holidays)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []Schedule
+	for _, sc := range cfg.Schedules {
+		if sc.Job != jobs.KindEquity && sc.Job != jobs.KindMutualFunds {
+			return nil, errors.Errorf("invalid scheduled job %q, expected %s or %s", sc.Job, jobs.KindEquity, jobs.KindMutualFunds)
+		}
+		var tradingDaysOnly bool
+		switch sc.Days {
+		case "", "trading":
+			tradingDaysOnly = true
+		case "all":
+			tradingDaysOnly = false
+		default:
+			return nil, errors.Errorf("invalid days %q for %s schedule, expected trading or all", sc.Days, sc.Job)
+		}
+		schedule, err := ParseSchedule(sc.Job, sc.At, tradingDaysOnly)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	return New(logger, jobRunner, schedules, location, calendar), nil
+}
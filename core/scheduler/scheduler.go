@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/jobs"
+	"github.com/pkg/errors"
+)
+
+type JobRunner interface {
+	Submit(kind string) (jobs.Job, bool, error)
+	Get(id string) (jobs.Job, bool)
+}
+
+// Schedule runs a refresh job every day at a wall clock time
+type Schedule struct {
+	Job    string
+	Hour   int
+	Minute int
+	// TradingDaysOnly skips weekends and exchange holidays
+	TradingDaysOnly bool
+}
+
+// ParseSchedule builds a schedule from an HH:MM time of day
+func ParseSchedule(job, at string, tradingDaysOnly bool) (Schedule, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return Schedule{}, errors.Errorf("invalid time %q for %s schedule, expected HH:MM", at, job)
+	}
+	return Schedule{
+		Job:             job,
+		Hour:            t.Hour(),
+		Minute:          t.Minute(),
+		TradingDaysOnly: tradingDaysOnly,
+	}, nil
+}
+
+func (s Schedule) String() string {
+	return fmt.Sprintf("%02d:%02d", s.Hour, s.Minute)
+}
+
+// NextRun returns the first run of s strictly after after, in loc
+func (s Schedule) NextRun(after time.Time, loc *time.Location, calendar *Calendar) time.Time {
+	after = after.In(loc)
+	next := time.Date(after.Year(), after.Month(), after.Day(), s.Hour, s.Minute, 0, 0, loc)
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	for s.TradingDaysOnly && !calendar.IsTradingDay(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Status is the last and next run of a schedule
+type Status struct {
+	Job             string     `json:"job"`
+	At              string     `json:"at"`
+	TradingDaysOnly bool       `json:"trading_days_only"`
+	NextRun         time.Time  `json:"next_run"`
+	LastRun         *time.Time `json:"last_run,omitempty"`
+	LastJobID       string     `json:"last_job_id,omitempty"`
+	LastJobState    jobs.State `json:"last_job_state,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// Scheduler submits refresh jobs on their schedules
+type Scheduler struct {
+	logger    *slog.Logger
+	jobs      JobRunner
+	schedules []Schedule
+	location  *time.Location
+	calendar  *Calendar
+
+	mu     sync.Mutex
+	status []Status
+}
+
+func New(logger *slog.Logger, jobRunner JobRunner, schedules []Schedule, location *time.Location, calendar *Calendar) *Scheduler {
+	s := &Scheduler{
+		logger:    logger,
+		jobs:      jobRunner,
+		schedules: schedules,
+		location:  location,
+		calendar:  calendar,
+		status:    make([]Status, len(schedules)),
+	}
+	now := time.Now()
+	for i, schedule := range schedules {
+		s.status[i] = Status{
+			Job:             schedule.Job,
+			At:              schedule.String(),
+			TradingDaysOnly: schedule.TradingDaysOnly,
+			NextRun:         schedule.NextRun(now, location, calendar),
+		}
+	}
+	return s
+}
+
+// Run blocks running every schedule until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := range s.schedules {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.runSchedule(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) runSchedule(ctx context.Context, i int) {
+	schedule := s.schedules[i]
+	for {
+		s.mu.Lock()
+		next := s.status[i].NextRun
+		s.mu.Unlock()
+
+		s.logger.Info("next scheduled refresh", slog.String("job", schedule.Job), slog.Time("at", next))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		job, attached, err := s.jobs.Submit(schedule.Job)
+
+		s.mu.Lock()
+		now := time.Now()
+		s.status[i].LastRun = &now
+		s.status[i].LastJobID = job.ID
+		s.status[i].LastError = ""
+		if err != nil {
+			s.status[i].LastError = err.Error()
+		}
+		s.status[i].NextRun = schedule.NextRun(now, s.location, s.calendar)
+		s.mu.Unlock()
+
+		if err != nil {
+			s.logger.Error("failed to submit scheduled refresh", slog.String("job", schedule.Job), slog.String("error", err.Error()))
+			continue
+		}
+		s.logger.Info("submitted scheduled refresh", slog.String("job", schedule.Job),
+			slog.String("job_id", job.ID), slog.Bool("attached", attached))
+	}
+}
+
+// Status returns every schedule with the state of the job it last submitted
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	status := make([]Status, len(s.status))
+	copy(status, s.status)
+	s.mu.Unlock()
+
+	for i := range status {
+		if status[i].LastJobID == "" {
+			continue
+		}
+		if job, ok := s.jobs.Get(status[i].LastJobID); ok {
+			status[i].LastJobState = job.State
+		}
+	}
+	return status
+}
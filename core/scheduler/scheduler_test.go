@@ -0,0 +1,58 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/scheduler"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+)
+
+func TestScheduleNextRun(t *testing.T) {
+	calendar, err := scheduler.NewCalendar([]string{"2024-10-31"})
+	assert.Nil(t, err)
+
+	equity, err := scheduler.ParseSchedule("equity", "16:30", true)
+	assert.Nil(t, err)
+	mf, err := scheduler.ParseSchedule("mutual_funds", "23:00", false)
+	assert.Nil(t, err)
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 10, day, hour, minute, 0, 0, utils.IST)
+	}
+
+	t.Run("runs later the same day", func(t *testing.T) {
+		// Monday
+		assert.Equal(t, at(28, 16, 30), equity.NextRun(at(28, 9, 0), utils.IST, calendar))
+	})
+
+	t.Run("moves to the next day once the time has passed", func(t *testing.T) {
+		assert.Equal(t, at(29, 16, 30), equity.NextRun(at(28, 16, 30), utils.IST, calendar))
+	})
+
+	t.Run("skips exchange holidays", func(t *testing.T) {
+		// Wednesday evening, Thursday the 31st is a holiday
+		assert.Equal(t, time.Date(2024, 11, 1, 16, 30, 0, 0, utils.IST), equity.NextRun(at(30, 17, 0), utils.IST, calendar))
+	})
+
+	t.Run("skips weekends", func(t *testing.T) {
+		// Friday evening
+		assert.Equal(t, at(28, 16, 30), equity.NextRun(at(25, 17, 0), utils.IST, calendar))
+	})
+
+	t.Run("runs every day when not limited to trading days", func(t *testing.T) {
+		assert.Equal(t, at(26, 23, 0), mf.NextRun(at(25, 23, 30), utils.IST, calendar))
+	})
+
+	t.Run("converts the reference time into the schedule timezone", func(t *testing.T) {
+		// 11:30 UTC is 17:00 IST on Monday
+		assert.Equal(t, at(29, 16, 30), equity.NextRun(time.Date(2024, 10, 28, 11, 30, 0, 0, time.UTC), utils.IST, calendar))
+	})
+}
+
+func TestParseSchedule(t *testing.T) {
+	_, err := scheduler.ParseSchedule("equity", "4:30pm", true)
+	assert.ErrorContains(t, err, "expected HH:MM")
+}
@@ -0,0 +1,385 @@
+package tax
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/pkg/errors"
+)
+
+// terms of a capital gain
+const (
+	ShortTerm = "short"
+	LongTerm  = "long"
+)
+
+// sections of the Income Tax Act the gains are taxed under
+const (
+	Section111A = "111A"
+	Section112A = "112A"
+	Section112  = "112"
+	// Section50AA deems the gains of debt funds bought from April 2023 short-term
+	Section50AA = "50AA"
+	// SectionSlab is a short-term gain taxed at the slab rate
+	SectionSlab = "slab"
+	// Section10_38 exempted long-term gains on listed equity and equity funds sold before April 2018
+	Section10_38 = "10(38)"
+)
+
+// categories of the lots
+const (
+	CategoryEquity     = "equity"
+	CategoryEquityFund = "equity_fund"
+	CategoryDebtFund   = "debt_fund"
+	CategoryOtherFund  = "other_fund"
+)
+
+var (
+	// budgetJuly2024 changed the rates and holding periods of sales from this day
+	budgetJuly2024 = date(2024, time.July, 23)
+	// debtFundCutoff is the day from which debt fund units are always short-term
+	debtFundCutoff = date(2023, time.April, 1)
+	// ltcgTaxed is the day from which long-term gains on listed equity and equity funds are taxed
+	ltcgTaxed = date(2018, time.April, 1)
+)
+
+// Lots serves the lots matched from the tradebook
+type Lots interface {
+	GetLots(method string) ([]service.OpenLot, []service.ClosedLot)
+}
+
+// Calculator computes capital gains tax of closed lots
+type Calculator struct {
+	slabRate float64
+	// exemptions overrides the 112A exemption by financial year
+	exemptions map[string]float64
+	// fundTypes is the kind of a fund by ISIN
+	fundTypes map[string]string
+	// fmvOverrides is the fair market value on 31 January 2018 by ISIN or symbol
+	fmvOverrides map[string]float64
+	actions      *service.CorporateActions
+	equityPrices EquityPrices
+	fundPrices   FundPrices
+}
+
+// rule is how a gain is taxed
+type rule struct {
+	category string
+	term     string
+	section  string
+	// rate is in percent
+	rate float64
+}
+
+// Lot is a closed lot classified for tax
+type Lot struct {
+	Account    string    `json:"account"`
+	Category   string    `json:"category"`
+	Symbol     string    `json:"symbol"`
+	ISIN       string    `json:"isin"`
+	BuyDate    time.Time `json:"buy_date"`
+	SellDate   time.Time `json:"sell_date"`
+	Quantity   float64   `json:"quantity"`
+	ActualCost float64   `json:"actual_cost"`
+	// Grandfathered lots were bought before February 2018 and sold long-term, their FMV is
+	// the value on 31 January 2018 per unit held today and FairMarketValue the value of the lot
+	Grandfathered   bool    `json:"grandfathered"`
+	FMV             float64 `json:"fmv,omitempty"`
+	FMVSource       string  `json:"fmv_source,omitempty"`
+	FairMarketValue float64 `json:"fair_market_value,omitempty"`
+	// EffectiveCost is the cost the gain is computed from, ActualCost includes the charges
+	// of the buy but STT, Expenditure is the charges of the sale but STT
+	EffectiveCost float64 `json:"effective_cost"`
+	Expenditure   float64 `json:"expenditure"`
+	// Proceeds is the full value of the sale, before its charges
+	Proceeds    float64 `json:"proceeds"`
+	Gain        float64 `json:"gain"`
+	HoldingDays int     `json:"holding_days"`
+	Term        string  `json:"term"`
+	Section     string  `json:"section"`
+	Rate        float64 `json:"rate"`
+	// Warning is set on a lot taxed on a guess, e.g. a grandfathered lot without its FMV
+	Warning string `json:"warning,omitempty"`
+}
+
+// Bucket totals the gains taxed under one section at one rate
+type Bucket struct {
+	Term    string  `json:"term"`
+	Section string  `json:"section"`
+	Rate    float64 `json:"rate"`
+	Gains   float64 `json:"gains"`
+	Losses  float64 `json:"losses"`
+	// Net is gains less losses, SetOff the losses of other buckets set off against it
+	Net       float64 `json:"net"`
+	SetOff    float64 `json:"set_off"`
+	Exemption float64 `json:"exemption"`
+	Taxable   float64 `json:"taxable"`
+	Tax       float64 `json:"tax"`
+}
+
+// CapitalGainsReport is the capital gains, and the tax on them, of a financial year
+type CapitalGainsReport struct {
+	FinancialYear string   `json:"financial_year"`
+	Account       string   `json:"account"`
+	Lots          []Lot    `json:"lots"`
+	Buckets       []Bucket `json:"buckets"`
+	// ShortTermGain and LongTermGain are net of losses, before set off
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+	// ExemptionLimit is the 112A exemption of the year, Exemption the part used
+	ExemptionLimit float64 `json:"exemption_limit"`
+	Exemption      float64 `json:"exemption"`
+	Taxable        float64 `json:"taxable"`
+	// EstimatedTax excludes surcharge and cess
+	EstimatedTax float64 `json:"estimated_tax"`
+	// losses left after set off, they are carried forward for eight years
+	ShortTermLossCarriedForward float64 `json:"short_term_loss_carried_forward"`
+	LongTermLossCarriedForward  float64 `json:"long_term_loss_carried_forward"`
+}
+
+// ParseFinancialYear parses a financial year as e.g. 2024-25, it returns its first day
+// and the first day of the next year
+func ParseFinancialYear(fy string) (time.Time, time.Time, error) {
+	invalid := errors.Errorf("invalid financial year %q, expected e.g. 2024-25", fy)
+	start, end, ok := strings.Cut(strings.TrimSpace(fy), "-")
+	if !ok || len(start) != 4 || len(end) != 2 {
+		return time.Time{}, time.Time{}, invalid
+	}
+	year, err := strconv.Atoi(start)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalid
+	}
+	if next, err := strconv.Atoi(end); err != nil || next != (year+1)%100 {
+		return time.Time{}, time.Time{}, invalid
+	}
+	return date(year, time.April, 1), date(year+1, time.April, 1), nil
+}
+
+// Exemption is the long-term gains exempt under section 112A in the financial year fy
+func (c *Calculator) Exemption(fy string) float64 {
+	if exemption, ok := c.exemptions[fy]; ok {
+		return exemption
+	}
+	start, _, err := ParseFinancialYear(fy)
+	if err != nil {
+		return 0
+	}
+	if start.Before(date(2024, time.April, 1)) {
+		return 100000
+	}
+	return 125000
+}
+
+// category classifies a lot by its asset class and, for funds, its configured type
+func (c *Calculator) category(assetClass, isin string) string {
+	if assetClass != service.AssetMutualFunds {
+		return CategoryEquity
+	}
+	switch c.fundTypes[strings.ToUpper(isin)] {
+	case FundDebt:
+		return CategoryDebtFund
+	case FundOther:
+		return CategoryOtherFund
+	}
+	return CategoryEquityFund
+}
+
+// classify returns how the gain of units of category bought on buy and sold on sell is taxed
+func (c *Calculator) classify(category string, buy, sell time.Time) rule {
+	buy, sell = day(buy), day(sell)
+	afterBudget := !sell.Before(budgetJuly2024)
+	r := rule{category: category}
+	switch category {
+	case CategoryEquity, CategoryEquityFund:
+		if !sell.After(buy.AddDate(1, 0, 0)) {
+			r.term, r.section, r.rate = ShortTerm, Section111A, 15
+			if afterBudget {
+				r.rate = 20
+			}
+			return r
+		}
+		r.term, r.section, r.rate = LongTerm, Section112A, 10
+		if afterBudget {
+			r.rate = 12.5
+		}
+		if sell.Before(ltcgTaxed) {
+			r.section, r.rate = Section10_38, 0
+		}
+		return r
+	case CategoryDebtFund:
+		if !buy.Before(debtFundCutoff) {
+			r.term, r.section, r.rate = ShortTerm, Section50AA, c.slabRate
+			return r
+		}
+	}
+
+	months, rate := 36, 20.0
+	if afterBudget {
+		months, rate = 24, 12.5
+	}
+	if !sell.After(buy.AddDate(0, months, 0)) {
+		r.term, r.section, r.rate = ShortTerm, SectionSlab, c.slabRate
+		return r
+	}
+	r.term, r.section, r.rate = LongTerm, Section112, rate
+	return r
+}
+
+// CapitalGains reports the capital gains of the lots sold in the financial year fy,
+// lots are always matched first in first out
+func (c *Calculator) CapitalGains(lots Lots, account, fy string) (CapitalGainsReport, error) {
+	if _, _, err := ParseFinancialYear(fy); err != nil {
+		return CapitalGainsReport{}, err
+	}
+	_, closed := lots.GetLots(service.CostBasisFIFO)
+	return c.capitalGains(closed, account, fy), nil
+}
+
+// taxLot classifies a closed lot and grandfathers its cost, STT is not deducted from the gain
+func (c *Calculator) taxLot(lot service.ClosedLot) Lot {
+	r := c.classify(c.category(lot.AssetClass, lot.ISIN), lot.BuyDate, lot.SellDate)
+	cost := lot.Cost - lot.BuyCharges.STT
+	proceeds := lot.Proceeds + lot.SellCharges.Total()
+	expenditure := lot.SellCharges.Total() - lot.SellCharges.STT
+	taxLot := Lot{
+		Account:       lot.Account,
+		Category:      r.category,
+		Symbol:        lot.Symbol,
+		ISIN:          lot.ISIN,
+		BuyDate:       lot.BuyDate,
+		SellDate:      lot.SellDate,
+		Quantity:      lot.Quantity,
+		ActualCost:    cost,
+		EffectiveCost: cost,
+		Expenditure:   expenditure,
+		Proceeds:      proceeds,
+		Gain:          proceeds - cost - expenditure,
+		HoldingDays:   lot.HoldingDays,
+		Term:          r.term,
+		Section:       r.section,
+		Rate:          r.rate,
+	}
+	c.grandfather(&taxLot, lot)
+	return taxLot
+}
+
+// capitalGains reports the gains of the closed lots sold in fy, fy must be valid
+func (c *Calculator) capitalGains(closed []service.ClosedLot, account, fy string) CapitalGainsReport {
+	from, to, _ := ParseFinancialYear(fy)
+	report := CapitalGainsReport{FinancialYear: fy, Account: account, Lots: []Lot{}, Buckets: []Bucket{}}
+	buckets := make(map[rule]*Bucket)
+	for _, lot := range closed {
+		sold := day(lot.SellDate)
+		if sold.Before(from) || !sold.Before(to) {
+			continue
+		}
+		taxLot := c.taxLot(lot)
+		report.Lots = append(report.Lots, taxLot)
+
+		r := rule{term: taxLot.Term, section: taxLot.Section, rate: taxLot.Rate}
+		bucket, ok := buckets[r]
+		if !ok {
+			bucket = &Bucket{Term: r.term, Section: r.section, Rate: r.rate}
+			buckets[r] = bucket
+		}
+		if taxLot.Gain >= 0 {
+			bucket.Gains += taxLot.Gain
+		} else {
+			bucket.Losses -= taxLot.Gain
+		}
+	}
+	for _, bucket := range buckets {
+		bucket.Net = bucket.Gains - bucket.Losses
+		report.Buckets = append(report.Buckets, *bucket)
+	}
+	// highest rate first, so losses and the exemption save the most tax
+	sort.Slice(report.Buckets, func(i, j int) bool {
+		a, b := report.Buckets[i], report.Buckets[j]
+		if a.Term != b.Term {
+			return a.Term == ShortTerm
+		}
+		if a.Rate != b.Rate {
+			return a.Rate > b.Rate
+		}
+		return a.Section < b.Section
+	})
+
+	report.ExemptionLimit = c.Exemption(fy)
+	c.setOff(&report)
+	return report
+}
+
+// setOff sets off the losses of the report against its gains, short-term losses against any
+// gain and long-term losses against long-term gains, then applies the 112A exemption
+func (c *Calculator) setOff(report *CapitalGainsReport) {
+	buckets := report.Buckets
+	for i := range buckets {
+		// exempt gains and losses are not set off
+		if buckets[i].Section == Section10_38 {
+			continue
+		}
+		if buckets[i].Term == ShortTerm {
+			report.ShortTermGain += buckets[i].Net
+		} else {
+			report.LongTermGain += buckets[i].Net
+		}
+	}
+
+	for i := range buckets {
+		loss := -buckets[i].Net
+		if loss <= 0 || buckets[i].Section == Section10_38 {
+			continue
+		}
+		for j := range buckets {
+			if loss <= 0 {
+				break
+			}
+			gain := buckets[j].Net - buckets[j].SetOff
+			if gain <= 0 || buckets[j].Section == Section10_38 {
+				continue
+			}
+			if buckets[i].Term == LongTerm && buckets[j].Term == ShortTerm {
+				continue
+			}
+			used := math.Min(loss, gain)
+			buckets[j].SetOff += used
+			loss -= used
+		}
+		if buckets[i].Term == ShortTerm {
+			report.ShortTermLossCarriedForward += loss
+		} else {
+			report.LongTermLossCarriedForward += loss
+		}
+	}
+
+	exemption := report.ExemptionLimit
+	for i := range buckets {
+		bucket := &buckets[i]
+		if bucket.Section == Section10_38 {
+			continue
+		}
+		bucket.Taxable = math.Max(bucket.Net-bucket.SetOff, 0)
+		if bucket.Section == Section112A && exemption > 0 {
+			bucket.Exemption = math.Min(exemption, bucket.Taxable)
+			bucket.Taxable -= bucket.Exemption
+			exemption -= bucket.Exemption
+			report.Exemption += bucket.Exemption
+		}
+		bucket.Tax = bucket.Taxable * bucket.Rate / 100
+		report.Taxable += bucket.Taxable
+		report.EstimatedTax += bucket.Tax
+	}
+}
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// day drops the time of day of t
+func day(t time.Time) time.Time {
+	return date(t.Year(), t.Month(), t.Day())
+}
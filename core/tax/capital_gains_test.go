@@ -0,0 +1,135 @@
+package tax_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+type fixedLots []service.ClosedLot
+
+func (f fixedLots) GetLots(method string) ([]service.OpenLot, []service.ClosedLot) {
+	return nil, f
+}
+
+func date(s string) time.Time {
+	t, _ := time.Parse(time.DateOnly, s)
+	return t
+}
+
+func closedLot(assetClass, isin, buy, sell string, gain float64) service.ClosedLot {
+	return service.ClosedLot{
+		Account:      config.DefaultAccount,
+		AssetClass:   assetClass,
+		Symbol:       isin,
+		ISIN:         isin,
+		BuyDate:      date(buy),
+		SellDate:     date(sell),
+		Quantity:     1,
+		Cost:         100000,
+		Proceeds:     100000 + gain,
+		RealisedGain: gain,
+	}
+}
+
+func TestCapitalGains(t *testing.T) {
+	calculator, err := tax.FromConfig(config.TaxConfig{FundTypes: map[string]string{"INF000DEBT01": "debt"}})
+	require.NoError(t, err)
+	lots := fixedLots{
+		closedLot(service.AssetEquity, "INE000000001", "2023-06-01", "2024-06-03", 150000),
+		closedLot(service.AssetEquity, "INE000000002", "2024-05-01", "2024-06-10", -20000),
+		closedLot(service.AssetEquity, "INE000000003", "2024-01-10", "2024-08-01", 50000),
+		closedLot(service.AssetMutualFunds, "INF000EQTY01", "2023-01-02", "2024-10-01", 100000),
+		closedLot(service.AssetMutualFunds, "INF000DEBT01", "2023-05-01", "2025-01-10", 10000),
+		closedLot(service.AssetMutualFunds, "INF000DEBT01", "2021-01-01", "2024-12-01", 5000),
+		// sold in the previous year
+		closedLot(service.AssetEquity, "INE000000001", "2023-06-01", "2024-03-01", 1000),
+	}
+
+	report, err := calculator.CapitalGains(lots, service.AllAccounts, "2024-25")
+	require.NoError(t, err)
+	require.Len(t, report.Lots, 6)
+	assert.Equal(t, tax.LongTerm, report.Lots[0].Term)
+	assert.Equal(t, tax.Section112A, report.Lots[0].Section)
+	assert.Equal(t, 10.0, report.Lots[0].Rate)
+	assert.Equal(t, tax.CategoryEquityFund, report.Lots[3].Category)
+	assert.Equal(t, 12.5, report.Lots[3].Rate)
+	assert.Equal(t, tax.Section50AA, report.Lots[4].Section)
+	assert.Equal(t, tax.Section112, report.Lots[5].Section)
+
+	assert.Equal(t, []tax.Bucket{
+		{Term: tax.ShortTerm, Section: tax.Section50AA, Rate: 30, Gains: 10000, Net: 10000, SetOff: 10000},
+		{Term: tax.ShortTerm, Section: tax.Section111A, Rate: 20, Gains: 50000, Net: 50000, SetOff: 10000, Taxable: 40000, Tax: 8000},
+		{Term: tax.ShortTerm, Section: tax.Section111A, Rate: 15, Losses: 20000, Net: -20000},
+		{Term: tax.LongTerm, Section: tax.Section112, Rate: 12.5, Gains: 5000, Net: 5000, Taxable: 5000, Tax: 625},
+		{Term: tax.LongTerm, Section: tax.Section112A, Rate: 12.5, Gains: 100000, Net: 100000, Exemption: 100000},
+		{Term: tax.LongTerm, Section: tax.Section112A, Rate: 10, Gains: 150000, Net: 150000, Exemption: 25000, Taxable: 125000, Tax: 12500},
+	}, report.Buckets)
+	assert.Equal(t, 40000.0, report.ShortTermGain)
+	assert.Equal(t, 255000.0, report.LongTermGain)
+	assert.Equal(t, 125000.0, report.ExemptionLimit)
+	assert.Equal(t, 125000.0, report.Exemption)
+	assert.Equal(t, 170000.0, report.Taxable)
+	assert.Equal(t, 21125.0, report.EstimatedTax)
+	assert.Zero(t, report.ShortTermLossCarriedForward)
+
+	t.Run("carries forward losses long-term gains cannot absorb", func(t *testing.T) {
+		report, err := calculator.CapitalGains(fixedLots{
+			closedLot(service.AssetEquity, "INE000000001", "2022-01-03", "2023-06-01", -30000),
+			closedLot(service.AssetEquity, "INE000000002", "2023-01-02", "2023-06-01", 20000),
+			closedLot(service.AssetEquity, "INE000000003", "2021-01-04", "2023-07-03", 10000),
+		}, service.AllAccounts, "2023-24")
+		require.NoError(t, err)
+		assert.Equal(t, 100000.0, report.ExemptionLimit)
+		assert.Equal(t, 20000.0, report.LongTermLossCarriedForward)
+		assert.Equal(t, 3000.0, report.EstimatedTax)
+	})
+
+	t.Run("long-term gains on equity and equity funds sold before April 2018 are exempt", func(t *testing.T) {
+		report, err := calculator.CapitalGains(fixedLots{
+			closedLot(service.AssetEquity, "INE000000001", "2016-01-04", "2018-03-01", 50000),
+			closedLot(service.AssetMutualFunds, "INF000EQTY01", "2015-06-01", "2018-02-01", 40000),
+			closedLot(service.AssetMutualFunds, "INF000DEBT01", "2014-06-02", "2018-02-01", 10000),
+		}, service.AllAccounts, "2017-18")
+		require.NoError(t, err)
+		assert.Equal(t, tax.Section10_38, report.Lots[0].Section)
+		assert.Equal(t, tax.CategoryEquityFund, report.Lots[1].Category)
+		assert.Equal(t, tax.Section10_38, report.Lots[1].Section)
+		assert.Zero(t, report.Lots[1].Rate)
+		assert.Equal(t, tax.Section112, report.Lots[2].Section)
+		assert.Equal(t, 10000.0, report.Taxable)
+	})
+
+	t.Run("holding exactly a year is short-term", func(t *testing.T) {
+		report, err := calculator.CapitalGains(fixedLots{
+			closedLot(service.AssetEquity, "INE000000001", "2023-06-01", "2024-06-01", 1000),
+		}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		assert.Equal(t, tax.ShortTerm, report.Lots[0].Term)
+	})
+
+	t.Run("deducts the charges of a lot but STT", func(t *testing.T) {
+		lot := closedLot(service.AssetEquity, "INE000000001", "2023-01-02", "2024-09-02", 0)
+		lot.BuyCharges = service.Charges{Brokerage: 20, STT: 100}
+		lot.SellCharges = service.Charges{Brokerage: 20, STT: 150}
+		lot.Cost, lot.Proceeds = 100120, 149830
+		lot.RealisedGain = lot.Proceeds - lot.Cost
+		report, err := calculator.CapitalGains(fixedLots{lot}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		assert.Equal(t, 100020.0, report.Lots[0].ActualCost)
+		assert.Equal(t, 20.0, report.Lots[0].Expenditure)
+		assert.Equal(t, 150000.0, report.Lots[0].Proceeds)
+		assert.Equal(t, 49960.0, report.Lots[0].Gain)
+	})
+
+	_, err = calculator.CapitalGains(lots, service.AllAccounts, "2024-26")
+	assert.Error(t, err)
+	_, err = tax.FromConfig(config.TaxConfig{FundTypes: map[string]string{"INF000DEBT01": "gilt"}})
+	assert.Error(t, err)
+}
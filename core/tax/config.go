@@ -0,0 +1,58 @@
+package tax
+
+import (
+	"strings"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/pkg/errors"
+)
+
+// kinds of funds, they are taxed differently
+const (
+	FundEquity = "equity"
+	FundDebt   = "debt"
+	FundOther  = "other"
+)
+
+const defaultSlabRate = 30
+
+// FromConfig builds a calculator for the configured rates and fund types
+func FromConfig(cfg config.TaxConfig) (*Calculator, error) {
+	c := &Calculator{
+		slabRate:   defaultSlabRate,
+		exemptions: make(map[string]float64),
+		fundTypes:  make(map[string]string),
+	}
+	if cfg.SlabRate < 0 || cfg.SlabRate > 100 {
+		return nil, errors.Errorf("invalid slab rate %v, expected a percentage", cfg.SlabRate)
+	}
+	if cfg.SlabRate > 0 {
+		c.slabRate = cfg.SlabRate
+	}
+	for fy, exemption := range cfg.LTCGExemption {
+		if _, _, err := ParseFinancialYear(fy); err != nil {
+			return nil, errors.Wrap(err, "invalid ltcg exemption")
+		}
+		if exemption < 0 {
+			return nil, errors.Errorf("invalid ltcg exemption %v of %s", exemption, fy)
+		}
+		c.exemptions[fy] = exemption
+	}
+	for isin, fundType := range cfg.FundTypes {
+		fundType = strings.ToLower(strings.TrimSpace(fundType))
+		switch fundType {
+		case FundEquity, FundDebt, FundOther:
+		default:
+			return nil, errors.Errorf("invalid fund type %q of %s, expected equity, debt or other", fundType, isin)
+		}
+		c.fundTypes[strings.ToUpper(strings.TrimSpace(isin))] = fundType
+	}
+	if cfg.FMVFile != "" {
+		values, err := LoadFairMarketValues(cfg.FMVFile)
+		if err != nil {
+			return nil, err
+		}
+		c.fmvOverrides = values
+	}
+	return c, nil
+}
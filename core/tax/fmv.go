@@ -0,0 +1,181 @@
+package tax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// sources of the fair market value of a grandfathered lot
+const (
+	FMVOverride     = "override"
+	FMVPriceHistory = "price_history"
+	// FMVUnavailable lots are taxed on their actual cost
+	FMVUnavailable = "unavailable"
+)
+
+// grandfatheredBefore is the day from which units are bought at their actual cost, gains on
+// units bought before it are computed from the higher of their cost and their value on 31 January 2018
+var grandfatheredBefore = date(2018, time.February, 1)
+
+// fmvDate is the day the fair market value of grandfathered units is taken on
+var fmvDate = date(2018, time.January, 31)
+
+// EquityPrices serves the cached candles of shares, the history as traded by symbol and the
+// latest candle adjusted for splits and bonuses like the lots
+type EquityPrices interface {
+	GetHistory(symbol string) []models.EquityPriceData
+	GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool)
+}
+
+// FundPrices serves the cached NAVs of funds by ISIN
+type FundPrices interface {
+	GetPriceMFTrendInTimeRange(symbol string, from time.Time, to time.Time) []models.MFPriceData
+	GetLatestNAV(isin string) (models.MFPriceData, bool)
+}
+
+// LoadFairMarketValues reads the fair market value per unit on 31 January 2018 keyed by
+// isin, or symbol when the isin is unset
+func LoadFairMarketValues(fileName string) (map[string]float64, error) {
+	records, err := service.ReadRecordsFile(fileName, []string{"fmv"})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read fmv file")
+	}
+	values := make(map[string]float64, len(records))
+	for i, record := range records {
+		key := strings.ToUpper(strings.TrimSpace(record["isin"]))
+		if key == "" {
+			key = strings.ToUpper(strings.TrimSpace(record["symbol"]))
+		}
+		if key == "" {
+			return nil, errors.Errorf("fmv %d of %s has neither isin nor symbol", i+1, fileName)
+		}
+		fmv, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(record["fmv"]), ",", ""), 64)
+		if err != nil || fmv <= 0 {
+			return nil, errors.Errorf("fmv %d of %s has an invalid fmv %q", i+1, fileName, record["fmv"])
+		}
+		values[key] = fmv
+	}
+	return values, nil
+}
+
+// SetCorporateActions sets the splits and bonuses fair market values per unit are adjusted by,
+// the quantities of lots are in shares held today
+func (c *Calculator) SetCorporateActions(actions *service.CorporateActions) {
+	c.actions = actions
+}
+
+// SetPriceHistory sets the price history the fair market values and latest prices are
+// looked up from, it must be set before the calculator is used
+func (c *Calculator) SetPriceHistory(equity EquityPrices, funds FundPrices) {
+	c.equityPrices, c.fundPrices = equity, funds
+}
+
+// fairMarketValue returns the value per unit on 31 January 2018 of the units of a lot, the
+// highest price of a share and the NAV of a fund, on the last trading day up to it. A share
+// is valued as the scrip bought, which a symbol change or demerger may have replaced, and
+// converted like the lot to a share held today.
+func (c *Calculator) fairMarketValue(lot service.ClosedLot) (float64, string) {
+	symbol, isin := lot.Symbol, lot.ISIN
+	if lot.Origin != nil {
+		symbol, isin = lot.Origin.Symbol, lot.Origin.ISIN
+	}
+	override, ok := c.fmvOverrides[strings.ToUpper(isin)]
+	if !ok {
+		override, ok = c.fmvOverrides[strings.ToUpper(symbol)]
+	}
+	if ok {
+		if lot.AssetClass == service.AssetMutualFunds {
+			return override, FMVOverride
+		}
+		return c.perShareHeld(lot, override), FMVOverride
+	}
+
+	if lot.AssetClass == service.AssetMutualFunds {
+		if c.fundPrices == nil {
+			return 0, FMVUnavailable
+		}
+		from := time.Date(2018, time.January, 1, 0, 0, 0, 0, utils.IST)
+		to := time.Date(2018, time.February, 1, 0, 0, 0, 0, utils.IST)
+		navs := c.fundPrices.GetPriceMFTrendInTimeRange(lot.ISIN, from, to)
+		if len(navs) == 0 {
+			return 0, FMVUnavailable
+		}
+		return float64(navs[len(navs)-1].Price), FMVPriceHistory
+	}
+	if c.equityPrices == nil {
+		return 0, FMVUnavailable
+	}
+	symbols := []string{symbol}
+	if lot.Origin != nil && lot.Origin.ISIN == lot.ISIN && symbol != lot.Symbol {
+		// a renamed scrip keeps its ISIN, its history may only be cached under the new symbol
+		symbols = append(symbols, lot.Symbol)
+	}
+	for _, symbol := range symbols {
+		if high, ok := highOnFMVDate(c.equityPrices.GetHistory(symbol)); ok {
+			return c.perShareHeld(lot, high), FMVPriceHistory
+		}
+	}
+	return 0, FMVUnavailable
+}
+
+// highOnFMVDate returns the highest price on the last trading day of January 2018 in history
+func highOnFMVDate(history []models.EquityPriceData) (float64, bool) {
+	var (
+		high  float64
+		found bool
+	)
+	for _, candle := range history {
+		day := utils.DateKey(candle.Timestamps)
+		if day < "2018-01-01" || day > fmvDate.Format(time.DateOnly) {
+			continue
+		}
+		high, found = float64(candle.High), true
+	}
+	return high, found
+}
+
+// perShareHeld converts a value per share of the scrip bought on 31 January 2018 to a value
+// per share of the lot, for the symbol change, demerger, splits and bonuses since
+func (c *Calculator) perShareHeld(lot service.ClosedLot, value float64) float64 {
+	trade := service.EquityTrade{Symbol: lot.Symbol, TradeDate: fmvDate, Quantity: 1, Price: value}
+	if lot.Origin != nil {
+		trade.OriginalSymbol, trade.SymbolChangedOn = lot.Origin.Symbol, lot.Origin.ChangedOn
+		trade.Price *= lot.Origin.CostFactor
+	}
+	return c.actions.AdjustTrade(trade).Price
+}
+
+// warningNoFMV is set on grandfathered lots taxed on their actual cost for want of a fair market value
+func warningNoFMV(lot service.ClosedLot) string {
+	scrip := lot.Symbol
+	if lot.Origin != nil && lot.Origin.Symbol != lot.Symbol {
+		scrip = fmt.Sprintf("%s, bought as %s", lot.Symbol, lot.Origin.Symbol)
+	}
+	return fmt.Sprintf("no fair market value on 31 January 2018 for %s, the gain is on the actual cost, add the value to the fmv file", scrip)
+}
+
+// grandfather sets the cost of a long-term lot of shares or equity funds bought before
+// February 2018 to the higher of its actual cost and its fair market value, capped at the proceeds
+func (c *Calculator) grandfather(lot *Lot, closed service.ClosedLot) {
+	if lot.Section != Section112A || !day(closed.BuyDate).Before(grandfatheredBefore) {
+		return
+	}
+	lot.Grandfathered = true
+	fmv, source := c.fairMarketValue(closed)
+	lot.FMVSource = source
+	if source == FMVUnavailable {
+		lot.Warning = warningNoFMV(closed)
+		return
+	}
+	lot.FMV = fmv
+	lot.FairMarketValue = fmv * closed.Quantity
+	lot.EffectiveCost = max(lot.ActualCost, min(lot.FairMarketValue, lot.Proceeds))
+	lot.Gain = lot.Proceeds - lot.EffectiveCost - lot.Expenditure
+}
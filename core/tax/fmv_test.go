@@ -0,0 +1,153 @@
+package tax_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+type fakePrices struct {
+	candles map[string][]models.EquityPriceData
+	navs    map[string][]models.MFPriceData
+}
+
+func (f fakePrices) GetHistory(symbol string) []models.EquityPriceData {
+	return f.candles[symbol]
+}
+
+func (f fakePrices) GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool) {
+	candles := f.candles[symbol]
+	if len(candles) == 0 {
+		return models.EquityPriceData{}, false
+	}
+	return candles[len(candles)-1], true
+}
+
+func (f fakePrices) GetLatestNAV(isin string) (models.MFPriceData, bool) {
+	navs := f.navs[isin]
+	if len(navs) == 0 {
+		return models.MFPriceData{}, false
+	}
+	return navs[len(navs)-1], true
+}
+
+func (f fakePrices) GetPriceMFTrendInTimeRange(symbol string, from, to time.Time) []models.MFPriceData {
+	var navs []models.MFPriceData
+	for _, nav := range f.navs[symbol] {
+		if !nav.Timestamps.Before(from) && nav.Timestamps.Before(to) {
+			navs = append(navs, nav)
+		}
+	}
+	return navs
+}
+
+func TestGrandfatheredCost(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "fmv.csv"), []byte("ISIN,Symbol,FMV\nINF000EQTY01,,30\n"), 0o644))
+	calculator, err := tax.FromConfig(config.TaxConfig{FMVFile: path.Join(dir, "fmv.csv")})
+	require.NoError(t, err)
+	calculator.SetPriceHistory(fakePrices{
+		candles: map[string][]models.EquityPriceData{"INFY": {
+			{Timestamps: date("2018-01-30"), High: 1500},
+			{Timestamps: date("2018-01-31"), High: 1600},
+			{Timestamps: date("2018-02-01"), High: 1700},
+		}},
+		navs: map[string][]models.MFPriceData{"INF000EQTY01": {{Timestamps: date("2018-01-31"), Price: 45}}},
+	}, fakePrices{})
+
+	infy := closedLot(service.AssetEquity, "INE009A01021", "2016-05-02", "2024-09-02", 150000)
+	infy.Symbol, infy.Quantity = "INFY", 100
+	fund := closedLot(service.AssetMutualFunds, "INF000EQTY01", "2017-03-01", "2024-06-03", -75000)
+	fund.Quantity = 1000
+	tcs := closedLot(service.AssetEquity, "INE467B01029", "2017-06-01", "2024-06-03", 50000)
+	tcs.Symbol = "TCS"
+	bought := closedLot(service.AssetEquity, "INE009A01021", "2018-02-01", "2024-06-03", 50000)
+	bought.Symbol = "INFY"
+
+	report, err := calculator.CapitalGains(fixedLots{fund, tcs, bought, infy}, service.AllAccounts, "2024-25")
+	require.NoError(t, err)
+	require.Len(t, report.Lots, 4)
+
+	// the override wins over the price history, the value is capped at the proceeds
+	assert.True(t, report.Lots[0].Grandfathered)
+	assert.Equal(t, tax.FMVOverride, report.Lots[0].FMVSource)
+	assert.Equal(t, 30000.0, report.Lots[0].FairMarketValue)
+	assert.Equal(t, 100000.0, report.Lots[0].EffectiveCost)
+	assert.Equal(t, -75000.0, report.Lots[0].Gain)
+
+	// a lot without a value is taxed on its actual cost and says so
+	assert.Equal(t, tax.FMVUnavailable, report.Lots[1].FMVSource)
+	assert.Equal(t, 50000.0, report.Lots[1].Gain)
+	assert.Contains(t, report.Lots[1].Warning, "no fair market value on 31 January 2018 for TCS")
+	assert.Empty(t, report.Lots[3].Warning)
+
+	assert.False(t, report.Lots[2].Grandfathered)
+	assert.Equal(t, 100000.0, report.Lots[2].EffectiveCost)
+
+	// the highest price on the last trading day up to 31 January 2018
+	assert.Equal(t, tax.FMVPriceHistory, report.Lots[3].FMVSource)
+	assert.Equal(t, 1600.0, report.Lots[3].FMV)
+	assert.Equal(t, 100000.0, report.Lots[3].ActualCost)
+	assert.Equal(t, 160000.0, report.Lots[3].EffectiveCost)
+	assert.Equal(t, 90000.0, report.Lots[3].Gain)
+
+	t.Run("an override is per share held on 31 January 2018", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path.Join(dir, "split.csv"), []byte("ISIN,Symbol,FMV\n,WIPRO,600\n"), 0o644))
+		calculator, err := tax.FromConfig(config.TaxConfig{FMVFile: path.Join(dir, "split.csv")})
+		require.NoError(t, err)
+		// a bonus before and a split after the day, the lot is in shares held today
+		calculator.SetCorporateActions(service.NewCorporateActions([]service.CorporateAction{
+			{Symbol: "WIPRO", Type: service.ActionBonus, ExDate: date("2017-06-13"), Ratio: "1:1", Factor: 2},
+			{Symbol: "WIPRO", Type: service.ActionSplit, ExDate: date("2019-03-06"), Ratio: "1:3", Factor: 3},
+		}))
+		wipro := closedLot(service.AssetEquity, "INE075A01022", "2016-05-02", "2024-09-02", 50000)
+		wipro.Symbol, wipro.Quantity = "WIPRO", 600
+
+		report, err := calculator.CapitalGains(fixedLots{wipro}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		assert.Equal(t, 200.0, report.Lots[0].FMV)
+		assert.Equal(t, 120000.0, report.Lots[0].FairMarketValue)
+		assert.Equal(t, 120000.0, report.Lots[0].EffectiveCost)
+		assert.Equal(t, 30000.0, report.Lots[0].Gain)
+	})
+
+	t.Run("a moved lot is valued as the scrip bought", func(t *testing.T) {
+		calculator, err := tax.FromConfig(config.TaxConfig{})
+		require.NoError(t, err)
+		// two shares of the acquirer for every share of the target, and a split of the acquirer since
+		calculator.SetCorporateActions(service.NewCorporateActions([]service.CorporateAction{
+			{Symbol: "ACQUIRER", Type: service.ActionSplit, ExDate: date("2021-01-05"), Ratio: "1:2", Factor: 2},
+		}))
+		calculator.SetPriceHistory(fakePrices{candles: map[string][]models.EquityPriceData{
+			"TARGET":   {{Timestamps: date("2018-01-31"), High: 400}},
+			"ACQUIRER": {{Timestamps: date("2018-01-31"), High: 900}},
+		}}, fakePrices{})
+
+		merged := closedLot(service.AssetEquity, "INE000ACQ001", "2016-05-02", "2024-09-02", 50000)
+		merged.Symbol, merged.Quantity = "ACQUIRER", 400
+		merged.Origin = &service.LotOrigin{Symbol: "TARGET", ISIN: "INE000TGT001", ChangedOn: date("2020-04-01"), CostFactor: 0.5}
+		orphan := merged
+		orphan.Origin = &service.LotOrigin{Symbol: "DELISTED", ISIN: "INE000DEL001", ChangedOn: date("2020-04-01"), CostFactor: 0.5}
+
+		report, err := calculator.CapitalGains(fixedLots{merged, orphan}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		// 400 a share of the target, half a share of the acquirer each, halved again by the split
+		assert.Equal(t, tax.FMVPriceHistory, report.Lots[0].FMVSource)
+		assert.Equal(t, 100.0, report.Lots[0].FMV)
+		assert.Equal(t, tax.FMVUnavailable, report.Lots[1].FMVSource)
+		assert.Contains(t, report.Lots[1].Warning, "ACQUIRER, bought as DELISTED")
+	})
+
+	require.NoError(t, os.WriteFile(path.Join(dir, "bad.yaml"), []byte("- symbol: INFY\n  fmv: abc\n"), 0o644))
+	_, err = tax.LoadFairMarketValues(path.Join(dir, "bad.yaml"))
+	assert.Error(t, err)
+}
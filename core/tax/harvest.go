@@ -0,0 +1,259 @@
+package tax
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+)
+
+// HarvestSuggestion is a sale of the oldest units of a holding, sells are matched first in
+// first out so a later lot cannot be sold on its own
+type HarvestSuggestion struct {
+	Account  string    `json:"account"`
+	Category string    `json:"category"`
+	Symbol   string    `json:"symbol"`
+	ISIN     string    `json:"isin"`
+	Price    float64   `json:"price"`
+	PricedAt time.Time `json:"priced_at"`
+	Units    float64   `json:"units"`
+	// Cost is the effective cost of the units, grandfathered where it applies
+	Cost          float64 `json:"cost"`
+	MarketValue   float64 `json:"market_value"`
+	Gain          float64 `json:"gain"`
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+	// TaxSaved is the tax saved this year when only this sale is made
+	TaxSaved float64 `json:"tax_saved"`
+	Lots     []Lot   `json:"lots"`
+}
+
+// HarvestReport suggests sales before the end of the financial year that book losses against
+// the gains realised so far, and gains that stay within the 112A exemption
+type HarvestReport struct {
+	FinancialYear string    `json:"financial_year"`
+	Account       string    `json:"account"`
+	Deadline      time.Time `json:"deadline"`
+	// realised gains, net of losses, and the tax on them so far
+	ShortTermGain float64 `json:"short_term_gain"`
+	LongTermGain  float64 `json:"long_term_gain"`
+	EstimatedTax  float64 `json:"estimated_tax"`
+	// Losses are the holdings whose oldest units are at a loss, TaxSaved is saved by selling all of them
+	Losses   []HarvestSuggestion `json:"losses"`
+	TaxSaved float64             `json:"tax_saved"`
+	// ExemptionRemaining is the 112A exemption the realised gains leave unused, Gains are
+	// long-term gains that can be booked tax free within it
+	ExemptionLimit     float64             `json:"exemption_limit"`
+	ExemptionRemaining float64             `json:"exemption_remaining"`
+	Gains              []HarvestSuggestion `json:"gains"`
+	// Unpriced are the holdings without a cached price
+	Unpriced []string `json:"unpriced"`
+}
+
+// holding is the open lots of a share or fund in an account, oldest first
+type holding struct {
+	account    string
+	assetClass string
+	symbol     string
+	isin       string
+	lots       []service.OpenLot
+}
+
+// Harvest suggests the sales of open lots, valued at their latest price, worth making before
+// the financial year of now ends
+func (c *Calculator) Harvest(lots Lots, account string, now time.Time) HarvestReport {
+	fy := utils.FinancialYear(now)
+	_, end, _ := ParseFinancialYear(fy)
+	open, closed := lots.GetLots(service.CostBasisFIFO)
+	realised := c.capitalGains(closed, account, fy)
+	report := HarvestReport{
+		FinancialYear:      fy,
+		Account:            account,
+		Deadline:           end.AddDate(0, 0, -1),
+		ShortTermGain:      realised.ShortTermGain,
+		LongTermGain:       realised.LongTermGain,
+		EstimatedTax:       realised.EstimatedTax,
+		Losses:             []HarvestSuggestion{},
+		ExemptionLimit:     realised.ExemptionLimit,
+		ExemptionRemaining: realised.ExemptionLimit - realised.Exemption,
+		Gains:              []HarvestSuggestion{},
+		Unpriced:           []string{},
+	}
+
+	var (
+		harvested  []service.ClosedLot
+		candidates []*holding
+	)
+	for _, h := range holdings(open) {
+		price, pricedAt, ok := c.latestPrice(h)
+		if !ok {
+			report.Unpriced = append(report.Unpriced, h.symbol)
+			continue
+		}
+		sales := make([]service.ClosedLot, len(h.lots))
+		for i, lot := range h.lots {
+			sales[i] = sale(lot, lot.Quantity, price, now)
+		}
+
+		// book the oldest units up to where the loss is largest
+		gain, loss, units := 0.0, 0.0, 0
+		for i, lot := range sales {
+			gain += c.taxLot(lot).Gain
+			if gain < loss {
+				loss, units = gain, i+1
+			}
+		}
+		if units == 0 {
+			candidates = append(candidates, h)
+			continue
+		}
+		suggestion := c.suggestion(h, sales[:units], price, pricedAt)
+		withSale := append(append([]service.ClosedLot{}, closed...), sales[:units]...)
+		suggestion.TaxSaved = realised.EstimatedTax - c.capitalGains(withSale, account, fy).EstimatedTax
+		report.Losses = append(report.Losses, suggestion)
+		harvested = append(harvested, sales[:units]...)
+	}
+	if len(harvested) > 0 {
+		withSales := append(append([]service.ClosedLot{}, closed...), harvested...)
+		report.TaxSaved = realised.EstimatedTax - c.capitalGains(withSales, account, fy).EstimatedTax
+	}
+	sort.SliceStable(report.Losses, func(i, j int) bool { return report.Losses[i].TaxSaved > report.Losses[j].TaxSaved })
+
+	remaining := report.ExemptionRemaining
+	for _, h := range candidates {
+		if remaining <= 0 {
+			break
+		}
+		price, pricedAt, _ := c.latestPrice(h)
+		var sales []service.ClosedLot
+		booked := 0.0
+		for _, lot := range h.lots {
+			whole := sale(lot, lot.Quantity, price, now)
+			taxLot := c.taxLot(whole)
+			if taxLot.Section != Section112A {
+				break
+			}
+			if booked+taxLot.Gain <= remaining {
+				sales = append(sales, whole)
+				booked += taxLot.Gain
+				continue
+			}
+			// part of the lot fills what is left of the exemption
+			units := (remaining - booked) / (taxLot.Gain / lot.Quantity)
+			if h.assetClass == service.AssetMutualFunds {
+				units = math.Floor(units*1000) / 1000
+			} else {
+				units = math.Floor(units)
+			}
+			if units > 0 {
+				partial := sale(lot, units, price, now)
+				sales = append(sales, partial)
+				booked += c.taxLot(partial).Gain
+			}
+			break
+		}
+		if booked <= 0 {
+			continue
+		}
+		report.Gains = append(report.Gains, c.suggestion(h, sales, price, pricedAt))
+		remaining -= booked
+	}
+	return report
+}
+
+// holdings groups open lots by account and share or fund, keeping them oldest first
+func holdings(open []service.OpenLot) []*holding {
+	var (
+		grouped []*holding
+		index   = make(map[[3]string]*holding)
+	)
+	for _, lot := range open {
+		key := [3]string{lot.Account, lot.AssetClass, lot.ISIN + "/" + lot.Symbol}
+		h, ok := index[key]
+		if !ok {
+			h = &holding{account: lot.Account, assetClass: lot.AssetClass, symbol: lot.Symbol, isin: lot.ISIN}
+			index[key] = h
+			grouped = append(grouped, h)
+		}
+		h.lots = append(h.lots, lot)
+	}
+	for _, h := range grouped {
+		sort.SliceStable(h.lots, func(i, j int) bool { return h.lots[i].BuyDate.Before(h.lots[j].BuyDate) })
+	}
+	sort.SliceStable(grouped, func(i, j int) bool {
+		if grouped[i].symbol != grouped[j].symbol {
+			return grouped[i].symbol < grouped[j].symbol
+		}
+		return grouped[i].account < grouped[j].account
+	})
+	return grouped
+}
+
+// latestPrice returns the last cached price of a share, adjusted to the share count of the
+// lots, or NAV of a fund
+func (c *Calculator) latestPrice(h *holding) (float64, time.Time, bool) {
+	if h.assetClass == service.AssetMutualFunds {
+		if c.fundPrices == nil {
+			return 0, time.Time{}, false
+		}
+		nav, ok := c.fundPrices.GetLatestNAV(h.isin)
+		return float64(nav.Price), nav.Timestamps, ok && nav.Price > 0
+	}
+	if c.equityPrices == nil {
+		return 0, time.Time{}, false
+	}
+	candle, ok := c.equityPrices.GetAdjustedLatestPrice(h.symbol)
+	return float64(candle.Close), candle.Timestamps, ok && candle.Close > 0
+}
+
+// sale is the closed lot of selling units of an open lot at price on now
+func sale(lot service.OpenLot, units, price float64, now time.Time) service.ClosedLot {
+	// the charges of the sale are not known ahead of it
+	buyCharges := lot.Charges.Scale(units / lot.Quantity)
+	cost := lot.Price*units + buyCharges.Total()
+	return service.ClosedLot{
+		Account:      lot.Account,
+		AssetClass:   lot.AssetClass,
+		Symbol:       lot.Symbol,
+		ISIN:         lot.ISIN,
+		BuyDate:      lot.BuyDate,
+		SellDate:     now,
+		Quantity:     units,
+		BuyPrice:     lot.Price,
+		SellPrice:    price,
+		BuyCharges:   buyCharges,
+		Cost:         cost,
+		Proceeds:     price * units,
+		RealisedGain: price*units - cost,
+		HoldingDays:  int(now.Sub(lot.BuyDate).Hours() / 24),
+		Origin:       lot.Origin,
+	}
+}
+
+// suggestion totals the sales of the oldest units of a holding
+func (c *Calculator) suggestion(h *holding, sales []service.ClosedLot, price float64, pricedAt time.Time) HarvestSuggestion {
+	suggestion := HarvestSuggestion{
+		Account:  h.account,
+		Symbol:   h.symbol,
+		ISIN:     h.isin,
+		Price:    price,
+		PricedAt: pricedAt,
+	}
+	for _, sale := range sales {
+		lot := c.taxLot(sale)
+		suggestion.Category = lot.Category
+		suggestion.Units += lot.Quantity
+		suggestion.Cost += lot.EffectiveCost
+		suggestion.MarketValue += lot.Proceeds
+		suggestion.Gain += lot.Gain
+		if lot.Term == ShortTerm {
+			suggestion.ShortTermGain += lot.Gain
+		} else {
+			suggestion.LongTermGain += lot.Gain
+		}
+		suggestion.Lots = append(suggestion.Lots, lot)
+	}
+	return suggestion
+}
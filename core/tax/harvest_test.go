@@ -0,0 +1,114 @@
+package tax_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+type openAndClosedLots struct {
+	open   []service.OpenLot
+	closed []service.ClosedLot
+}
+
+func (l openAndClosedLots) GetLots(method string) ([]service.OpenLot, []service.ClosedLot) {
+	return l.open, l.closed
+}
+
+func openLot(assetClass, symbol, isin, buy string, quantity, price float64) service.OpenLot {
+	return service.OpenLot{
+		Account:    config.DefaultAccount,
+		AssetClass: assetClass,
+		Symbol:     symbol,
+		ISIN:       isin,
+		BuyDate:    date(buy),
+		Quantity:   quantity,
+		Price:      price,
+		Cost:       quantity * price,
+	}
+}
+
+func TestHarvest(t *testing.T) {
+	calculator, err := tax.FromConfig(config.TaxConfig{})
+	require.NoError(t, err)
+	calculator.SetPriceHistory(fakePrices{candles: map[string][]models.EquityPriceData{
+		"INFY": {{Timestamps: date("2025-02-07"), Close: 1600}},
+		"TCS":  {{Timestamps: date("2025-02-07"), Close: 3500}},
+	}}, fakePrices{navs: map[string][]models.MFPriceData{
+		"INF879O01027": {{Timestamps: date("2025-02-07"), Price: 45}},
+	}})
+
+	lots := openAndClosedLots{
+		open: []service.OpenLot{
+			openLot(service.AssetEquity, "TCS", "INE467B01029", "2021-01-04", 100, 2000),
+			openLot(service.AssetEquity, "INFY", "INE009A01021", "2024-06-03", 10, 2000),
+			openLot(service.AssetEquity, "INFY", "INE009A01021", "2024-09-02", 10, 1500),
+			openLot(service.AssetEquity, "WIPRO", "INE075A01022", "2024-09-02", 10, 500),
+			openLot(service.AssetMutualFunds, "PARAG PARIKH FLEXI CAP FUND", "INF879O01027", "2024-10-01", 1000, 50),
+		},
+		closed: []service.ClosedLot{
+			closedLot(service.AssetEquity, "INE000000001", "2024-03-01", "2024-08-01", 100000),
+			closedLot(service.AssetEquity, "INE000000002", "2022-01-03", "2024-09-02", 25000),
+		},
+	}
+
+	report := calculator.Harvest(lots, service.AllAccounts, date("2025-02-10"))
+	assert.Equal(t, "2024-25", report.FinancialYear)
+	assert.Equal(t, date("2025-03-31"), report.Deadline)
+	assert.Equal(t, 20000.0, report.EstimatedTax)
+	assert.Equal(t, []string{"WIPRO"}, report.Unpriced)
+
+	// only the older INFY lot is at a loss, selling it cannot include the newer one
+	require.Len(t, report.Losses, 2)
+	fund, infy := report.Losses[0], report.Losses[1]
+	assert.Equal(t, tax.CategoryEquityFund, fund.Category)
+	assert.Equal(t, -5000.0, fund.ShortTermGain)
+	assert.Equal(t, 1000.0, fund.TaxSaved)
+	assert.Equal(t, "INFY", infy.Symbol)
+	assert.Equal(t, 10.0, infy.Units)
+	assert.Equal(t, -4000.0, infy.Gain)
+	assert.Equal(t, 800.0, infy.TaxSaved)
+	assert.Equal(t, 1800.0, report.TaxSaved)
+
+	// 25000 of the 125000 exemption is used, 66 shares book 99000 of long-term gains tax free
+	assert.Equal(t, 100000.0, report.ExemptionRemaining)
+	require.Len(t, report.Gains, 1)
+	assert.Equal(t, "TCS", report.Gains[0].Symbol)
+	assert.Equal(t, 66.0, report.Gains[0].Units)
+	assert.Equal(t, 99000.0, report.Gains[0].LongTermGain)
+}
+
+func TestHarvestSplitAfterLastCandle(t *testing.T) {
+	// the cache has not caught up with a 1:2 split, the lot is in shares held after it
+	trendsDir := t.TempDir()
+	history, err := json.Marshal([]models.EquityPriceData{{Timestamps: date("2025-02-05"), Close: 1600}})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(path.Join(trendsDir, "EQ"), 0o755))
+	require.NoError(t, os.WriteFile(path.Join(trendsDir, "EQ", "INFY.json"), history, 0o644))
+	cache := service.GetEquityTrendCache(slog.Default(), []service.ScriptName{"INFY"}, nil, trendsDir, 1)
+	cache.SetCorporateActions(service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "INFY", Type: service.ActionSplit, ExDate: date("2025-02-07"), Ratio: "1:2", Factor: 2},
+	}))
+
+	calculator, err := tax.FromConfig(config.TaxConfig{})
+	require.NoError(t, err)
+	calculator.SetPriceHistory(cache, fakePrices{})
+
+	lots := openAndClosedLots{open: []service.OpenLot{
+		openLot(service.AssetEquity, "INFY", "INE009A01021", "2024-06-03", 20, 1000),
+	}}
+	report := calculator.Harvest(lots, service.AllAccounts, date("2025-02-10"))
+	require.Len(t, report.Losses, 1)
+	assert.Equal(t, 800.0, report.Losses[0].Price)
+	assert.Equal(t, -4000.0, report.Losses[0].Gain)
+}
@@ -0,0 +1,170 @@
+package tax
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// when the units of a Schedule 112A row were acquired
+const (
+	AcquiredBefore2018 = "BE"
+	AcquiredAfter2018  = "AE"
+)
+
+// schedule112AColumns is the header of the Schedule 112A CSV imported by the income-tax utility
+var schedule112AColumns = []string{
+	"Share/Unit acquired",
+	"ISIN Code",
+	"Name of the Share/Unit",
+	"No. of Shares/Units",
+	"Sale-price per Share/Unit",
+	"Full Value of Consideration(Total Sale Value)",
+	"Cost of acquisition without indexation",
+	"Cost of acquisition",
+	"If the long term capital asset was acquired before 01.02.2018, Lower of Total Sale Value and Total Fair Market Value",
+	"Fair Market Value per share/unit as on 31st January,2018",
+	"Total Fair Market Value of capital asset as per section 55(2)(ac)",
+	"Expenditure wholly and exclusively in connection with transfer",
+	"Total deductions",
+	"Balance",
+}
+
+// Schedule112ARow is a row of Schedule 112A, the long-term gains on shares and equity fund units
+type Schedule112ARow struct {
+	Acquired  string  `json:"acquired"`
+	ISIN      string  `json:"isin"`
+	Name      string  `json:"name"`
+	Units     float64 `json:"units"`
+	SalePrice float64 `json:"sale_price"`
+	SaleValue float64 `json:"sale_value"`
+	// Cost is the higher of the actual cost and the capped fair market value
+	Cost       float64 `json:"cost"`
+	ActualCost float64 `json:"actual_cost"`
+	// CappedFMV is the lower of the sale value and FairMarketValue, set for units acquired before February 2018
+	CappedFMV       float64 `json:"capped_fmv"`
+	FMV             float64 `json:"fmv"`
+	FairMarketValue float64 `json:"fair_market_value"`
+	Expenditure     float64 `json:"expenditure"`
+	Deductions      float64 `json:"deductions"`
+	Balance         float64 `json:"balance"`
+	// Warning is set on a row that needs fixing before it is filed, it is not exported
+	Warning string `json:"warning,omitempty"`
+}
+
+// warningNoISIN is set on the rows of lots traded without an ISIN, e.g. on Upstox
+const warningNoISIN = "the trades have no ISIN, fill it in before filing"
+
+// Schedule112A returns the Schedule 112A rows of the financial year fy. Units acquired from
+// February 2018 are reported scrip-wise, by ISIN and symbol, grandfathered ones lot by lot as
+// their cost is computed per lot. Rows of lots without an ISIN carry a warning.
+func (c *Calculator) Schedule112A(lots Lots, account, fy string) ([]Schedule112ARow, error) {
+	report, err := c.CapitalGains(lots, account, fy)
+	if err != nil {
+		return nil, err
+	}
+	rows := []Schedule112ARow{}
+	scrips := make(map[string]int)
+	for _, lot := range report.Lots {
+		if lot.Section != Section112A {
+			continue
+		}
+		if lot.Grandfathered {
+			row := Schedule112ARow{
+				Acquired:        AcquiredBefore2018,
+				ISIN:            lot.ISIN,
+				Name:            lot.Symbol,
+				Units:           lot.Quantity,
+				SaleValue:       lot.Proceeds,
+				Cost:            lot.EffectiveCost,
+				ActualCost:      lot.ActualCost,
+				FMV:             lot.FMV,
+				FairMarketValue: lot.FairMarketValue,
+				Expenditure:     lot.Expenditure,
+			}
+			row.CappedFMV = math.Min(lot.FairMarketValue, lot.Proceeds)
+			var warnings []string
+			if lot.ISIN == "" {
+				warnings = append(warnings, warningNoISIN)
+			}
+			if lot.FMVSource == FMVUnavailable {
+				// the row would be filed with no fair market value and the actual cost
+				warnings = append(warnings, lot.Warning)
+			}
+			row.Warning = strings.Join(warnings, "; ")
+			rows = append(rows, row)
+			continue
+		}
+		// trades of some brokers have no ISIN, the symbol keeps their scrips apart
+		key := lot.ISIN + "/" + lot.Symbol
+		i, ok := scrips[key]
+		if !ok {
+			i = len(rows)
+			scrips[key] = i
+			row := Schedule112ARow{Acquired: AcquiredAfter2018, ISIN: lot.ISIN, Name: lot.Symbol}
+			if lot.ISIN == "" {
+				row.Warning = warningNoISIN
+			}
+			rows = append(rows, row)
+		}
+		rows[i].Units += lot.Quantity
+		rows[i].SaleValue += lot.Proceeds
+		rows[i].Cost += lot.EffectiveCost
+		rows[i].ActualCost += lot.ActualCost
+		rows[i].Expenditure += lot.Expenditure
+	}
+	for i := range rows {
+		row := &rows[i]
+		if row.Units > 0 {
+			row.SalePrice = row.SaleValue / row.Units
+		}
+		row.Deductions = row.Cost + row.Expenditure
+		row.Balance = row.SaleValue - row.Deductions
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Acquired != rows[j].Acquired {
+			return rows[i].Acquired == AcquiredBefore2018
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows, nil
+}
+
+// WriteSchedule112A writes rows in the column layout of the Schedule 112A CSV import
+func WriteSchedule112A(w io.Writer, rows []Schedule112ARow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(schedule112AColumns); err != nil {
+		return errors.Wrap(err, "unable to write schedule 112A")
+	}
+	for _, row := range rows {
+		fmvColumns := []string{"", "", ""}
+		if row.Acquired == AcquiredBefore2018 {
+			fmvColumns = []string{amount(row.CappedFMV), amount(row.FMV), amount(row.FairMarketValue)}
+		}
+		record := append([]string{
+			row.Acquired,
+			row.ISIN,
+			row.Name,
+			strconv.FormatFloat(math.Round(row.Units*1000)/1000, 'f', -1, 64),
+			amount(row.SalePrice),
+			amount(row.SaleValue),
+			amount(row.Cost),
+			amount(row.ActualCost),
+		}, fmvColumns...)
+		record = append(record, amount(row.Expenditure), amount(row.Deductions), amount(row.Balance))
+		if err := writer.Write(record); err != nil {
+			return errors.Wrap(err, "unable to write schedule 112A")
+		}
+	}
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "unable to write schedule 112A")
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,89 @@
+package tax_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func TestSchedule112A(t *testing.T) {
+	calculator, err := tax.FromConfig(config.TaxConfig{})
+	require.NoError(t, err)
+	calculator.SetPriceHistory(fakePrices{candles: map[string][]models.EquityPriceData{
+		"INFY": {{Timestamps: date("2018-01-31"), High: 1200}},
+	}}, fakePrices{})
+
+	grandfathered := closedLot(service.AssetEquity, "INE009A01021", "2016-05-02", "2024-09-02", 50000)
+	grandfathered.Symbol, grandfathered.Quantity = "INFY", 100
+	first := closedLot(service.AssetEquity, "INE467B01029", "2021-06-01", "2024-09-02", 20000)
+	first.Symbol, first.Quantity = "TCS", 30
+	second := closedLot(service.AssetEquity, "INE467B01029", "2022-06-01", "2024-12-02", -5000)
+	second.Symbol, second.Quantity = "TCS", 20
+	// short-term gains are not part of Schedule 112A
+	short := closedLot(service.AssetEquity, "INE467B01029", "2024-06-01", "2024-12-02", 1000)
+	short.Symbol = "TCS"
+
+	rows, err := calculator.Schedule112A(fixedLots{grandfathered, first, second, short}, service.AllAccounts, "2024-25")
+	require.NoError(t, err)
+	assert.Equal(t, []tax.Schedule112ARow{
+		{
+			Acquired: tax.AcquiredBefore2018, ISIN: "INE009A01021", Name: "INFY", Units: 100, SalePrice: 1500,
+			SaleValue: 150000, Cost: 120000, ActualCost: 100000, CappedFMV: 120000, FMV: 1200, FairMarketValue: 120000,
+			Deductions: 120000, Balance: 30000,
+		},
+		{
+			Acquired: tax.AcquiredAfter2018, ISIN: "INE467B01029", Name: "TCS", Units: 50, SalePrice: 4300,
+			SaleValue: 215000, Cost: 200000, ActualCost: 200000, Deductions: 200000, Balance: 15000,
+		},
+	}, rows)
+
+	var out bytes.Buffer
+	require.NoError(t, tax.WriteSchedule112A(&out, rows))
+	records, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Len(t, records[0], 14)
+	assert.Equal(t, []string{"BE", "INE009A01021", "INFY", "100", "1500.00", "150000.00", "120000.00", "100000.00",
+		"120000.00", "1200.00", "120000.00", "0.00", "120000.00", "30000.00"}, records[1])
+	assert.Equal(t, []string{"AE", "INE467B01029", "TCS", "50", "4300.00", "215000.00", "200000.00", "200000.00",
+		"", "", "", "0.00", "200000.00", "15000.00"}, records[2])
+
+	t.Run("keeps scrips without an ISIN apart and warns about them", func(t *testing.T) {
+		infy := closedLot(service.AssetEquity, "", "2021-06-01", "2024-09-02", 20000)
+		infy.Symbol = "INFY"
+		tcs := closedLot(service.AssetEquity, "", "2022-06-01", "2024-12-02", 10000)
+		tcs.Symbol = "TCS"
+
+		rows, err := calculator.Schedule112A(fixedLots{infy, tcs}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, "INFY", rows[0].Name)
+		assert.Equal(t, 120000.0, rows[0].SaleValue)
+		assert.Equal(t, "TCS", rows[1].Name)
+		assert.Equal(t, 110000.0, rows[1].SaleValue)
+		for _, row := range rows {
+			assert.Empty(t, row.ISIN)
+			assert.NotEmpty(t, row.Warning)
+		}
+	})
+
+	t.Run("warns about grandfathered rows without a fair market value", func(t *testing.T) {
+		wipro := closedLot(service.AssetEquity, "INE075A01022", "2016-05-02", "2024-09-02", 50000)
+		wipro.Symbol = "WIPRO"
+
+		rows, err := calculator.Schedule112A(fixedLots{wipro}, service.AllAccounts, "2024-25")
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, tax.AcquiredBefore2018, rows[0].Acquired)
+		assert.Zero(t, rows[0].FMV)
+		assert.Contains(t, rows[0].Warning, "no fair market value on 31 January 2018 for WIPRO")
+	})
+}
@@ -7,9 +7,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Mryashbhardwaj/marketAnalysis/core/jobs"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/scheduler"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/tax"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
 	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/gorilla/mux"
 )
 
 // all methos responses should be properly defined
@@ -19,18 +23,42 @@ type Tradebook interface {
 	GetMutualFundsList() map[service.FundName]service.ISIN
 	GetEquityList() []service.ScriptName
 	GetEqBreakdown(symbol string) (service.BreakdownResponse, error)
+	GetTradebookStatus() service.TradebookStatus
+	GetIngestionReport() service.IngestionReport
+	GetAccounts() []service.AccountSummary
+	GetDividends(from, to time.Time) service.DividendReport
+	GetEquityPnL(prices service.LatestPrices) service.PortfolioPnL
+	GetSymbolPnL(symbol string, prices service.LatestPrices) (service.SymbolPnL, error)
+	GetLots(method string) ([]service.OpenLot, []service.ClosedLot)
+	// Account views the tradebook of one account, "all" or empty views every account
+	Account(name string) (*service.TradebookService, error)
 }
 
 type EquityTrendCache interface {
 	GetPriceTrendInTimeRange(symbol string, from time.Time, to time.Time) []models.EquityPriceData
 	GetGrowthComparison(symbols []string, from, to time.Time) []map[string]interface{}
-	BuildPriceHistoryCache(allShares []service.ScriptName) error
+	GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool)
 }
 
 type MFTrendCache interface {
 	GetPriceMFTrendInTimeRange(symbol string, from time.Time, to time.Time) []models.MFPriceData
 	GetMFGrowthComparison(symbols []string, from, to time.Time) []map[string]interface{}
-	BuildMFPriceHistoryCache(map[service.FundName]service.ISIN) error
+}
+
+type JobManager interface {
+	Submit(kind string) (jobs.Job, bool, error)
+	Get(id string) (jobs.Job, bool)
+	List() []jobs.Job
+}
+
+type Scheduler interface {
+	Status() []scheduler.Status
+}
+
+type TaxCalculator interface {
+	CapitalGains(lots tax.Lots, account, fy string) (tax.CapitalGainsReport, error)
+	Schedule112A(lots tax.Lots, account, fy string) ([]tax.Schedule112ARow, error)
+	Harvest(lots tax.Lots, account string, now time.Time) tax.HarvestReport
 }
 
 type Handler struct {
@@ -38,17 +66,39 @@ type Handler struct {
 	tradebookService Tradebook
 	equityTrendCache EquityTrendCache
 	mfTrendCache     MFTrendCache
+	jobs             JobManager
+	scheduler        Scheduler
+	tax              TaxCalculator
 }
 
-func GetHandler(tradebookService Tradebook, equityTrendCache EquityTrendCache, mfTrendCache MFTrendCache) *Handler {
+// GetHandler wires the API handlers, scheduler is nil when scheduled refreshes are disabled
+func GetHandler(tradebookService Tradebook, equityTrendCache EquityTrendCache, mfTrendCache MFTrendCache, jobManager JobManager, scheduler Scheduler, taxCalculator TaxCalculator) *Handler {
 	return &Handler{
 		logger:           slog.Default(),
 		tradebookService: tradebookService,
 		equityTrendCache: equityTrendCache,
 		mfTrendCache:     mfTrendCache,
+		jobs:             jobManager,
+		scheduler:        scheduler,
+		tax:              taxCalculator,
 	}
 }
 
+// accountTradebook returns the tradebook of the account in the account query parameter,
+// it responds with a bad request for an unknown account
+func (h Handler) accountTradebook(w http.ResponseWriter, r *http.Request) (Tradebook, bool) {
+	tradebook, err := h.tradebookService.Account(r.URL.Query().Get("account"))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return tradebook, true
+}
+
+func (h Handler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, h.tradebookService.GetAccounts())
+}
+
 func (h Handler) GetTrend(w http.ResponseWriter, r *http.Request) {
 	symbol := r.URL.Query().Get("symbol")
 	from, to, err := utils.GetTimeRange(r)
@@ -60,12 +110,16 @@ func (h Handler) GetTrend(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetMFSummary(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
 	from, to, err := utils.GetTimeRange(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	utils.RespondWithJSON(w, 200, h.tradebookService.GetMFSummmary(from, to))
+	utils.RespondWithJSON(w, 200, tradebook.GetMFSummmary(from, to))
 }
 
 func (h Handler) GetMFTrend(w http.ResponseWriter, r *http.Request) {
@@ -79,13 +133,17 @@ func (h Handler) GetMFTrend(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetMFPositions(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
 	symbol := r.URL.Query().Get("symbol")
 	from, to, err := utils.GetTimeRange(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	utils.RespondWithJSON(w, 200, h.tradebookService.GetPriceMFPositionsInTimeRange(symbol, from, to))
+	utils.RespondWithJSON(w, 200, tradebook.GetPriceMFPositionsInTimeRange(symbol, from, to))
 }
 
 func (h Handler) GetTrendComparison(w http.ResponseWriter, r *http.Request) {
@@ -128,7 +186,11 @@ func (h Handler) GetMFGrowthComparison(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetMutualFundsList(w http.ResponseWriter, r *http.Request) {
-	mfMap := h.tradebookService.GetMutualFundsList()
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	mfMap := tradebook.GetMutualFundsList()
 	var fundList []string
 	for fundName, insin := range mfMap {
 		fundList = append(fundList, fmt.Sprintf("%s:%s", fundName, insin))
@@ -137,38 +199,185 @@ func (h Handler) GetMutualFundsList(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Handler) GetEquityList(w http.ResponseWriter, r *http.Request) {
-	eqList := h.tradebookService.GetEquityList()
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	eqList := tradebook.GetEquityList()
 	utils.RespondWithJSON(w, 200, eqList)
 }
 
-// make server object and get logger and config in handler
+// refreshJobResponse is the job a refresh request was queued as, attached is
+// true when the request joined a refresh that was already running
+type refreshJobResponse struct {
+	jobs.Job
+	Attached bool `json:"attached"`
+}
+
 func (h Handler) RefreshPriceHistory(w http.ResponseWriter, r *http.Request) {
-	allShares := h.tradebookService.GetEquityList()
-	err := h.equityTrendCache.BuildPriceHistoryCache(allShares)
+	h.submitRefresh(w, jobs.KindEquity)
+}
+
+func (h Handler) RefreshMFPriceHistory(w http.ResponseWriter, r *http.Request) {
+	h.submitRefresh(w, jobs.KindMutualFunds)
+}
+
+func (h Handler) submitRefresh(w http.ResponseWriter, kind string) {
+	job, attached, err := h.jobs.Submit(kind)
 	if err != nil {
-		utils.RespondWithJSON(w, 505, err.Error())
+		utils.RespondWithJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	utils.RespondWithJSON(w, 200, "Price History Refreshed Successfully")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	utils.RespondWithJSON(w, http.StatusAccepted, refreshJobResponse{Job: job, Attached: attached})
 }
 
-func (h Handler) RefreshMFPriceHistory(w http.ResponseWriter, r *http.Request) {
-	allFunds := h.tradebookService.GetMutualFundsList()
-	err := h.mfTrendCache.BuildMFPriceHistoryCache(allFunds)
+func (h Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobs.Get(mux.Vars(r)["id"])
+	if !ok {
+		utils.RespondWithJSON(w, http.StatusNotFound, "job not found")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, job)
+}
+
+func (h Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, h.jobs.List())
+}
+
+func (h Handler) GetSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		utils.RespondWithJSON(w, http.StatusOK, []scheduler.Status{})
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, h.scheduler.Status())
+}
+
+func (h Handler) GetTradebookStatus(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, tradebook.GetTradebookStatus())
+}
+
+func (h Handler) GetIngestionReport(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, tradebook.GetIngestionReport())
+}
+
+func (h Handler) GetDividends(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	from, to, err := utils.GetTimeRange(r)
 	if err != nil {
-		utils.RespondWithJSON(w, 505, err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	utils.RespondWithJSON(w, 200, "Price History Refreshed Successfully")
+	utils.RespondWithJSON(w, http.StatusOK, tradebook.GetDividends(from, to))
+}
+
+// GetEquityPnL reports the lots and profit and loss of every holding, or of symbol when set
+func (h Handler) GetEquityPnL(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		utils.RespondWithJSON(w, http.StatusOK, tradebook.GetEquityPnL(h.equityTrendCache))
+		return
+	}
+	pnl, err := tradebook.GetSymbolPnL(symbol, h.equityTrendCache)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, pnl)
+}
+
+// GetCapitalGains reports the capital gains of the financial year in fy, the current one when unset
+func (h Handler) GetCapitalGains(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	report, err := h.tax.CapitalGains(tradebook, taxAccount(r), taxYear(r))
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, report)
+}
+
+// GetSchedule112A exports the Schedule 112A rows of the financial year in fy as CSV, or as JSON with format=json
+func (h Handler) GetSchedule112A(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	fy := taxYear(r)
+	rows, err := h.tax.Schedule112A(tradebook, taxAccount(r), fy)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.URL.Query().Get("format") == "json" {
+		utils.RespondWithJSON(w, http.StatusOK, rows)
+		return
+	}
+	for _, row := range rows {
+		if row.Warning != "" {
+			h.logger.Warn("schedule 112A row needs fixing", slog.String("name", row.Name), slog.String("warning", row.Warning))
+		}
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=schedule_112a_%s.csv", fy))
+	if err := tax.WriteSchedule112A(w, rows); err != nil {
+		h.logger.Error("failed to write schedule 112A", slog.String("error", err.Error()))
+	}
+}
+
+// GetHarvest suggests sales to book losses and tax free gains before the financial year ends
+func (h Handler) GetHarvest(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, h.tax.Harvest(tradebook, taxAccount(r), time.Now().In(utils.IST)))
+}
+
+// taxYear is the financial year in the fy query parameter, the current one when unset
+func taxYear(r *http.Request) string {
+	if fy := r.URL.Query().Get("fy"); fy != "" {
+		return fy
+	}
+	return utils.FinancialYear(time.Now().In(utils.IST))
+}
+
+func taxAccount(r *http.Request) string {
+	if account := r.URL.Query().Get("account"); account != "" {
+		return account
+	}
+	return service.AllAccounts
 }
 
 func (h Handler) GetEqBreakdown(w http.ResponseWriter, r *http.Request) {
+	tradebook, ok := h.accountTradebook(w, r)
+	if !ok {
+		return
+	}
 	symbol := r.URL.Query().Get("symbol")
 	if symbol == "" {
 		utils.RespondWithJSON(w, 400, "Missing 'symbol' parameter")
 		return
 	}
-	breakdown, err := h.tradebookService.GetEqBreakdown(symbol)
+	breakdown, err := tradebook.GetEqBreakdown(symbol)
 	if err != nil {
 		utils.RespondWithJSON(w, 500, err.Error())
 		return
@@ -29,6 +29,8 @@ type MFSummary struct {
 	AllTimeAbsoluteReturnPercentage float64
 	XIRR                            float64
 	CAGR                            float64
+	// Dividends is the IDCW paid out by the fund
+	Dividends float64
 }
 
 type MFHoldingsData struct {
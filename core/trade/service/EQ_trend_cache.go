@@ -1,15 +1,15 @@
 package service
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
+	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
-	MC "github.com/Mryashbhardwaj/marketAnalysis/external/trackers/moneyControl"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
 	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
 	"github.com/pkg/errors"
 )
@@ -23,48 +23,125 @@ func (s ScriptName) String() string {
 type EquityTrade struct {
 	Isin               string
 	Symbol             string
-	TradeDate          string
+	TradeDate          time.Time
 	Exchange           string
 	Segment            string
 	Series             string
 	TradeType          string
 	Auction            string
-	Quantity           string
-	Price              string
+	Quantity           float64
+	Price              float64
 	TradeId            string
 	OrderId            string
 	OrderExecutionTime string
+	// Broker the trade was placed with
+	Broker string
+	// Account the trade was read for
+	Account string
+	// OriginalSymbol and OriginalISIN are the scrip traded when the trade was moved to a
+	// successor symbol and SymbolChangedOn the day the successor took over from it
+	OriginalSymbol  string
+	OriginalISIN    string
+	SymbolChangedOn time.Time
+	// CostFactor is the price of a share of the symbol per price of a share of the scrip
+	// traded, from the exchange ratio and the cost split of a demerger, 0 when unchanged
+	CostFactor float64
+	// Charges are set by the charge rates of the tradebook
+	Charges Charges
 }
 
+func (e EquityTrade) GetTime() time.Time {
+	return e.TradeDate
+}
+
+func (e EquityTrade) GetPrice() float64 {
+	return e.Price
+}
+
+// historyStart is the earliest point in time price history is requested from
+var historyStart = time.Unix(490147200, 0)
+
+// EquityTrendCache is safe for concurrent use, readers get an immutable snapshot
+// of the history while refreshes publish updated snapshots
 type EquityTrendCache struct {
-	History map[ScriptName][]models.EquityPriceData
-	logger  *slog.Logger
+	history     *priceHistory[ScriptName, models.EquityPriceData]
+	actions     atomic.Pointer[CorporateActions]
+	lineages    atomic.Pointer[SymbolLineages]
+	logger      *slog.Logger
+	provider    trackers.PriceProvider
+	store       trendStore
+	concurrency int
 }
 
-func GetEquityTrendCache(logger *slog.Logger, allShares []ScriptName) *EquityTrendCache {
-	history := BuildEquityPriceHistoryCacheFromFile(allShares)
-	if history == nil {
-		history = make(map[ScriptName][]models.EquityPriceData)
-	}
+func GetEquityTrendCache(logger *slog.Logger, allShares []ScriptName, provider trackers.PriceProvider, trendsDir string, concurrency int) *EquityTrendCache {
+	history := BuildEquityPriceHistoryCacheFromFile(logger, trendsDir, allShares)
 
 	marketTrendCache := &EquityTrendCache{
-		History: history,
-		logger:  logger,
+		history:     newPriceHistory(history),
+		logger:      logger,
+		provider:    provider,
+		store:       trendStore{directory: path.Join(trendsDir, "EQ")},
+		concurrency: concurrency,
 	}
 	return marketTrendCache
 }
 
+// SetCorporateActions sets the splits and bonuses candles are adjusted for, the cache
+// and GetHistory keep the candles as traded
+func (e *EquityTrendCache) SetCorporateActions(actions *CorporateActions) {
+	e.actions.Store(actions)
+}
+
+// SetSymbolLineages sets the renames and mergers followed to the symbol history is kept under
+func (e *EquityTrendCache) SetSymbolLineages(lineages *SymbolLineages) {
+	e.lineages.Store(lineages)
+}
+
+// GetHistory returns every cached candle of symbol as traded, the slice is shared and must not be modified
+func (e *EquityTrendCache) GetHistory(symbol string) []models.EquityPriceData {
+	return e.history.get(ScriptName(symbol))
+}
+
+// GetLatestPrice returns the latest candle of symbol, or of its current symbol when it was renamed or merged
+func (e *EquityTrendCache) GetLatestPrice(symbol string) (models.EquityPriceData, bool) {
+	history := e.history.get(e.lineages.Load().Current(ScriptName(symbol)))
+	if len(history) == 0 {
+		return models.EquityPriceData{}, false
+	}
+	return history[len(history)-1], true
+}
+
+// GetAdjustedLatestPrice is GetLatestPrice adjusted for the splits and bonuses after the
+// candle, so it values the adjusted quantities of trades and lots
+func (e *EquityTrendCache) GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool) {
+	current := e.lineages.Load().Current(ScriptName(symbol))
+	history := e.history.get(current)
+	if len(history) == 0 {
+		return models.EquityPriceData{}, false
+	}
+	latest := []models.EquityPriceData{history[len(history)-1]}
+	e.actions.Load().AdjustCandles(current, latest)
+	return latest[0], true
+}
+
+// GetPriceTrendInTimeRange returns a copy of the candles between from and to adjusted
+// for splits and bonuses, with the percent change from the first candle of the range
 func (e *EquityTrendCache) GetPriceTrendInTimeRange(symbol string, from, to time.Time) []models.EquityPriceData {
-	if len(e.History[ScriptName(symbol)]) == 0 {
+	current := e.lineages.Load().Current(ScriptName(symbol))
+	history := e.history.get(current)
+	if len(history) == 0 {
 		return nil
 	}
-	startIndex := utils.MomentBinarySearch(e.History[ScriptName(symbol)], from)
-	endIndex := utils.MomentBinarySearch(e.History[ScriptName(symbol)], to)
+	startIndex := utils.MomentBinarySearch(history, from)
+	endIndex := utils.MomentBinarySearch(history, to)
 
-	requestedRange := e.History[ScriptName(symbol)][startIndex:endIndex]
-	if len(requestedRange) == 0 {
+	if endIndex <= startIndex {
 		return nil
 	}
+	requestedRange := make([]models.EquityPriceData, endIndex-startIndex)
+	copy(requestedRange, history[startIndex:endIndex])
+	e.actions.Load().AdjustCandles(current, requestedRange)
+
 	startPrice := requestedRange[0].Close
 	for i := range requestedRange {
 		requestedRange[i].PercentChange = ((requestedRange[i].Close - startPrice) / startPrice) * 100
@@ -72,20 +149,73 @@ func (e *EquityTrendCache) GetPriceTrendInTimeRange(symbol string, from, to time
 	return requestedRange
 }
 
-// todo: make this function an object function
-func (e *EquityTrendCache) BuildPriceHistoryCache(allShares []ScriptName) error {
+// BuildPriceHistoryCache fetches only the candles missing since the last cached one
+// for every symbol, the full history is fetched for symbols not cached yet
+func (e *EquityTrendCache) BuildPriceHistoryCache(allShares []ScriptName, progress RefreshProgress) RefreshReport {
+	return e.BuildPriceHistoryCacheSince(allShares, time.Time{}, progress)
+}
+
+// BuildPriceHistoryCacheSince is BuildPriceHistoryCache refetching every candle from since
+// onwards, cached candles in that window are replaced. A zero since refreshes incrementally.
+func (e *EquityTrendCache) BuildPriceHistoryCacheSince(allShares []ScriptName, since time.Time, progress RefreshProgress) RefreshReport {
+	// history is fetched under the current symbol of renamed and merged ones
+	lineages := e.lineages.Load()
+	seen := make(map[ScriptName]struct{}, len(allShares))
+	tasks := make([]refreshTask, 0, len(allShares))
+	for _, symbol := range allShares {
+		current := lineages.Current(symbol)
+		if _, ok := seen[current]; ok {
+			continue
+		}
+		seen[current] = struct{}{}
+		tasks = append(tasks, refreshTask{symbol: current.String()})
+	}
+	return runRefresh(tasks, e.concurrency, progress, func(symbol string) (int, error) {
+		return e.refreshSymbol(ScriptName(symbol), since)
+	})
+}
+
+func (e *EquityTrendCache) refreshSymbol(symbol ScriptName, since time.Time) (int, error) {
+	existing := e.history.get(symbol)
+
+	from := historyStart
+	if len(existing) > 0 {
+		// refetch the last cached day as it may have been cached mid session
+		from = existing[len(existing)-1].Timestamps
+	}
+	if !since.IsZero() && since.Before(from) {
+		from = since
+	}
+	history, err := e.provider.GetEQHistory(symbol.String(), from, time.Now())
+	if err != nil {
+		if len(existing) > 0 && errors.Is(err, trackers.ErrNoData) {
+			// nothing traded since the last refresh
+			return 0, errUpToDate
+		}
+		return 0, errors.Wrap(err, "error fetching history")
+	}
+	return e.mergePriceHistory(symbol, history)
+}
+
+// ImportPriceHistory merges the history served by provider into the cache, candles
+// from the provider replace cached candles of the same day. Symbols the provider
+// has no data for are left untouched.
+func (e *EquityTrendCache) ImportPriceHistory(provider trackers.PriceProvider, allShares []ScriptName) error {
 	var errorList []string
 	for _, symbol := range allShares {
-		history, err := fetchTradeHistories(symbol)
+		history, err := provider.GetEQHistory(symbol.String(), historyStart, time.Now())
 		if err != nil {
-			fmt.Printf("error fetching history for %s, err:%s", symbol, err.Error())
-			errorList = append(errorList, fmt.Sprintf("error fetching history for %s, err:%s", symbol, err.Error()))
+			if errors.Is(err, trackers.ErrNoData) {
+				continue
+			}
+			errorList = append(errorList, fmt.Sprintf("error importing history for %s from %s, err:%s", symbol, provider.Name(), err.Error()))
 			continue
 		}
-		e.History[symbol] = history
-		err = persistInFile(string(symbol), history)
-		if err != nil {
-			fmt.Printf("error persisting history for %s, err:%s", symbol, err.Error())
+		if len(history) == 0 {
+			continue
+		}
+		_, err = e.mergePriceHistory(symbol, history)
+		if err != nil && !errors.Is(err, errUpToDate) {
 			errorList = append(errorList, fmt.Sprintf("error persisting history for %s, err:%s", symbol, err.Error()))
 			continue
 		}
@@ -96,6 +226,32 @@ func (e *EquityTrendCache) BuildPriceHistoryCache(allShares []ScriptName) error
 	return errors.New(strings.Join(errorList, "\n"))
 }
 
+// mergePriceHistory dedupes history into the cached candles of symbol and persists the result,
+// it returns the number of candles added or errUpToDate when nothing changed
+func (e *EquityTrendCache) mergePriceHistory(symbol ScriptName, history []models.EquityPriceData) (int, error) {
+	e.history.mu.Lock()
+	defer e.history.mu.Unlock()
+
+	existing := e.history.get(symbol)
+	merged := mergeByDay(existing, history)
+	if len(merged) == len(existing) && isExtension(existing, merged, sameCandle) {
+		return 0, errUpToDate
+	}
+	e.history.publish(symbol, merged)
+	err := saveTrend(e.store, string(symbol), existing, merged, sameCandle)
+	if err != nil {
+		return 0, errors.Wrap(err, "error persisting history")
+	}
+	return len(merged) - len(existing), nil
+}
+
+func sameCandle(a, b models.EquityPriceData) bool {
+	return a.Timestamps.Equal(b.Timestamps) &&
+		a.Open == b.Open && a.Close == b.Close &&
+		a.High == b.High && a.Low == b.Low &&
+		a.Volume == b.Volume
+}
+
 func (e *EquityTrendCache) GetGrowthComparison(symbols []string, from, to time.Time) []map[string]interface{} {
 	// This holds, for each timestamp, how much each symbol has changed
 	growthMap := make(map[time.Time]map[string]float32)
@@ -126,61 +282,17 @@ func (e *EquityTrendCache) GetGrowthComparison(symbols []string, from, to time.T
 	return response
 }
 
-func BuildEquityPriceHistoryCacheFromFile(allShares []ScriptName) map[ScriptName][]models.EquityPriceData {
+func BuildEquityPriceHistoryCacheFromFile(logger *slog.Logger, trendsDir string, allShares []ScriptName) map[ScriptName][]models.EquityPriceData {
+	store := trendStore{directory: path.Join(trendsDir, "EQ")}
 	shareHistory := make(map[ScriptName][]models.EquityPriceData)
 	for _, symbol := range allShares {
-		history, err := buildEquityCacheFromFile(symbol)
+		history := []models.EquityPriceData{}
+		err := store.read(string(symbol), &history)
 		if err != nil {
-			fmt.Printf("error fetching history from file for %s, err:%s\n", symbol, err.Error())
+			logger.Warn("failed to read equity price history", slog.String("symbol", string(symbol)), slog.String("error", err.Error()))
 			continue
 		}
 		shareHistory[symbol] = history
 	}
 	return shareHistory
 }
-
-func buildEquityCacheFromFile(symbol ScriptName) ([]models.EquityPriceData, error) {
-	fileName := fmt.Sprintf("./data/trends/EQ/%s.json", symbol)
-	fileContent, err := os.ReadFile(fileName)
-	if err != nil {
-		return nil, err
-	}
-	trend := []models.EquityPriceData{}
-	err = json.Unmarshal(fileContent, &trend)
-	return trend, err
-}
-
-func persistInFile(symbol string, trend interface{}) error {
-	fileContent, err := json.Marshal(trend)
-	if err != nil {
-		return err
-	}
-	if _, err := os.Stat("./data/trends/EQ/"); os.IsNotExist(err) {
-		if err := os.MkdirAll("./data/trends/EQ/", os.ModePerm); err != nil {
-			return errors.Wrap(err, "unable to create EQ trends directory")
-		}
-	}
-	fileName := fmt.Sprintf("./data/trends/EQ/%s.json", symbol)
-	return os.WriteFile(fileName, fileContent, os.ModePerm)
-}
-
-func fetchTradeHistories(script ScriptName) ([]models.EquityPriceData, error) {
-	k, err := MC.GetEQHistoryFromMoneyControll(script.String())
-	if err != nil {
-		return nil, err
-	}
-
-	candlePoints := make([]models.EquityPriceData, len(k.T))
-
-	for i, timeStamp := range k.T {
-		candlePoints[i] = models.EquityPriceData{
-			Close:      k.C[i],
-			High:       k.H[i],
-			Volume:     k.V[i],
-			Open:       k.O[i],
-			Low:        k.L[i],
-			Timestamps: time.Unix(timeStamp, 0),
-		}
-	}
-	return candlePoints, nil
-}
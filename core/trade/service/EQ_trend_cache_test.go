@@ -0,0 +1,192 @@
+package service_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+)
+
+// fakeProvider serves candles from a fixed series, honouring the requested range
+type fakeProvider struct {
+	mu        sync.Mutex
+	candles   []models.EquityPriceData
+	navs      []models.MFPriceData
+	requested []time.Time
+}
+
+func (f *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (f *fakeProvider) GetEQHistory(_ string, from, to time.Time) ([]models.EquityPriceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requested = append(f.requested, from)
+	var history []models.EquityPriceData
+	for _, c := range f.candles {
+		if c.Timestamps.Before(from) || c.Timestamps.After(to) {
+			continue
+		}
+		history = append(history, c)
+	}
+	if len(history) == 0 {
+		return nil, trackers.ErrNoData
+	}
+	return history, nil
+}
+
+func (f *fakeProvider) GetMFHistory(_ string, from, to time.Time) ([]models.MFPriceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requested = append(f.requested, from)
+	var history []models.MFPriceData
+	for _, n := range f.navs {
+		if n.Timestamps.Before(from) || n.Timestamps.After(to) {
+			continue
+		}
+		history = append(history, n)
+	}
+	if len(history) == 0 {
+		return nil, trackers.ErrNoData
+	}
+	return history, nil
+}
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestBuildPriceHistoryCache(t *testing.T) {
+	t.Run("only fetches and appends candles after the last cached one", func(t *testing.T) {
+		dir := t.TempDir()
+		provider := &fakeProvider{candles: []models.EquityPriceData{
+			{Timestamps: day(1), Close: 10},
+			{Timestamps: day(2), Close: 11},
+		}}
+		cache := service.GetEquityTrendCache(slog.Default(), nil, provider, dir, 2)
+
+		report := cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil)
+		assert.Nil(t, report.Err())
+		assert.Equal(t, 2, report.Results[0].RowsAdded)
+		assert.Len(t, cache.GetHistory("INFY"), 2)
+
+		provider.candles = append(provider.candles, models.EquityPriceData{Timestamps: day(3), Close: 12})
+		report = cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil)
+		assert.Nil(t, report.Err())
+		assert.Equal(t, 1, report.Results[0].RowsAdded)
+		assert.Equal(t, day(2), provider.requested[1])
+		assert.Len(t, cache.GetHistory("INFY"), 3)
+
+		var persisted []models.EquityPriceData
+		content, err := os.ReadFile(path.Join(dir, "EQ", "INFY.json"))
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(content, &persisted))
+		assert.Len(t, persisted, 3)
+		assert.Equal(t, float32(12), persisted[2].Close)
+	})
+
+	t.Run("replaces a changed last candle", func(t *testing.T) {
+		dir := t.TempDir()
+		provider := &fakeProvider{candles: []models.EquityPriceData{
+			{Timestamps: day(1), Close: 10},
+			{Timestamps: day(2), Close: 11},
+		}}
+		cache := service.GetEquityTrendCache(slog.Default(), nil, provider, dir, 2)
+		assert.Nil(t, cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil).Err())
+
+		provider.candles[1].Close = 11.5
+		assert.Nil(t, cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil).Err())
+
+		reloaded := service.GetEquityTrendCache(slog.Default(), []service.ScriptName{"INFY"}, provider, dir, 2)
+		assert.Len(t, reloaded.GetHistory("INFY"), 2)
+		assert.Equal(t, float32(11.5), reloaded.GetHistory("INFY")[1].Close)
+	})
+
+	t.Run("reports every symbol on a concurrent refresh", func(t *testing.T) {
+		dir := t.TempDir()
+		provider := &fakeProvider{candles: []models.EquityPriceData{{Timestamps: day(1), Close: 10}}}
+		cache := service.GetEquityTrendCache(slog.Default(), nil, provider, dir, 2)
+
+		var progressCalls int
+		shares := []service.ScriptName{"INFY", "TCS", "WIPRO"}
+		report := cache.BuildPriceHistoryCache(shares, func(done, total int, _ service.RefreshResult) {
+			progressCalls++
+			assert.Equal(t, 3, total)
+			assert.Equal(t, progressCalls, done)
+		})
+		assert.Equal(t, 3, report.OK)
+		assert.Equal(t, 3, progressCalls)
+		assert.Len(t, report.Results, 3)
+	})
+
+	t.Run("treats no new candles as up to date", func(t *testing.T) {
+		dir := t.TempDir()
+		provider := &fakeProvider{candles: []models.EquityPriceData{{Timestamps: day(1), Close: 10}}}
+		cache := service.GetEquityTrendCache(slog.Default(), nil, provider, dir, 2)
+		assert.Nil(t, cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil).Err())
+
+		provider.candles = nil
+		report := cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, service.RefreshSkipped, report.Results[0].Status)
+		assert.Len(t, cache.GetHistory("INFY"), 1)
+	})
+}
+
+func TestEquityTrendCacheConcurrentAccess(t *testing.T) {
+	// meaningful with the race detector, go test -race
+	var candles []models.EquityPriceData
+	for d := 1; d <= 28; d++ {
+		candles = append(candles, models.EquityPriceData{Timestamps: day(d), Close: float32(100 + d)})
+	}
+	provider := &fakeProvider{candles: candles[:10]}
+	cache := service.GetEquityTrendCache(slog.Default(), nil, provider, t.TempDir(), 4)
+	shares := []service.ScriptName{"INFY", "TCS", "WIPRO", "HCLTECH"}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				trend := cache.GetPriceTrendInTimeRange("INFY", day(1), day(28))
+				if len(trend) > 0 {
+					assert.Equal(t, float32(0), trend[0].PercentChange)
+				}
+				cache.GetGrowthComparison([]string{"INFY", "TCS"}, day(1), day(28))
+			}
+		}()
+	}
+
+	for i := 10; i <= len(candles); i += 6 {
+		provider.mu.Lock()
+		provider.candles = candles[:i]
+		provider.mu.Unlock()
+		report := cache.BuildPriceHistoryCache(shares, nil)
+		assert.Nil(t, report.Err())
+	}
+	close(done)
+	readers.Wait()
+
+	// range queries hand out copies, the percent change of one range never leaks into the cache
+	late := cache.GetPriceTrendInTimeRange("INFY", day(5), day(28))
+	assert.Equal(t, float32(0), late[0].PercentChange)
+	assert.Equal(t, float32(0), cache.GetHistory("INFY")[4].PercentChange)
+	assert.Len(t, cache.GetHistory("INFY"), len(candles))
+}
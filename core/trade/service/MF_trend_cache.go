@@ -1,15 +1,13 @@
 package service
 
 import (
-	"encoding/json"
-	"fmt"
 	"log/slog"
-	"os"
-	"strings"
+	"path"
+	"sync/atomic"
 	"time"
 
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
-	MC "github.com/Mryashbhardwaj/marketAnalysis/external/trackers/moneyControl"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
 	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
 	"github.com/pkg/errors"
 )
@@ -27,6 +25,15 @@ type MutualFundsTrade struct {
 	TradeID            string
 	OrderID            string
 	OrderExecutionTime string
+	// Broker the trade was placed with
+	Broker string
+	// Account the trade was read for
+	Account string
+	// Folio, Amount and Transaction are set for trades read from a CAS statement,
+	// Transaction is the kind stated, e.g. sip, switch_out or dividend_reinvest
+	Folio       string
+	Amount      float64
+	Transaction string
 }
 
 func (m MutualFundsTrade) GetTime() time.Time {
@@ -44,43 +51,79 @@ func (f FundName) String() string {
 
 type ISIN string
 
+// MFTrendCache is safe for concurrent use, readers get an immutable snapshot
+// of the history while refreshes publish updated snapshots
 type MFTrendCache struct {
-	History        map[ISIN][]models.MFPriceData
-	ISINToFundName map[ISIN]FundName
+	history        *priceHistory[ISIN, models.MFPriceData]
+	isinToFundName atomic.Pointer[map[ISIN]FundName]
 	logger         *slog.Logger
+	provider       trackers.PriceProvider
+	store          trendStore
+	concurrency    int
 }
 
-func GetMFTrendCache(logger *slog.Logger, allFunds map[ISIN]FundName) *MFTrendCache {
+func GetMFTrendCache(logger *slog.Logger, allFunds map[ISIN]FundName, provider trackers.PriceProvider, trendsDir string, concurrency int) *MFTrendCache {
 	m := &MFTrendCache{
-		History:        make(map[ISIN][]models.MFPriceData),
-		ISINToFundName: make(map[ISIN]FundName),
-		logger:         logger,
+		logger:      logger,
+		provider:    provider,
+		store:       trendStore{directory: path.Join(trendsDir, "MF")},
+		concurrency: concurrency,
 	}
-	m.ISINToFundName = allFunds
+	m.SetFundNames(allFunds)
 
+	history := make(map[ISIN][]models.MFPriceData)
 	for isin := range allFunds {
-		history, err := buildFundsCacheFromFile(isin)
+		trend := []models.MFPriceData{}
+		err := m.store.read(string(isin), &trend)
 		if err != nil {
-			fmt.Printf("error reading MF cache for %s: %s\n", isin, err)
+			logger.Warn("failed to read mutual fund price history", slog.String("isin", string(isin)), slog.String("error", err.Error()))
 			continue
 		}
-		m.History[isin] = history
+		history[isin] = trend
 	}
+	m.history = newPriceHistory(history)
 
 	return m
 }
 
+// SetFundNames replaces the names used to label funds in growth comparisons
+func (m *MFTrendCache) SetFundNames(allFunds map[ISIN]FundName) {
+	if allFunds == nil {
+		allFunds = make(map[ISIN]FundName)
+	}
+	m.isinToFundName.Store(&allFunds)
+}
+
+// GetHistory returns every cached NAV of isin, the slice is shared and must not be modified
+func (m *MFTrendCache) GetHistory(isin string) []models.MFPriceData {
+	return m.history.get(ISIN(isin))
+}
+
+// GetLatestNAV returns the latest NAV of isin
+func (m *MFTrendCache) GetLatestNAV(isin string) (models.MFPriceData, bool) {
+	history := m.history.get(ISIN(isin))
+	if len(history) == 0 {
+		return models.MFPriceData{}, false
+	}
+	return history[len(history)-1], true
+}
+
+// GetPriceMFTrendInTimeRange returns a copy of the NAVs between from and to
+// with the percent change from the first NAV of the range
 func (m *MFTrendCache) GetPriceMFTrendInTimeRange(symbol string, from, to time.Time) []models.MFPriceData {
-	if len(m.History[ISIN(symbol)]) == 0 {
+	history := m.history.get(ISIN(symbol))
+	if len(history) == 0 {
 		return nil
 	}
-	startIndex := utils.MomentBinarySearch(m.History[ISIN(symbol)], from)
-	endIndex := utils.MomentBinarySearch(m.History[ISIN(symbol)], to)
+	startIndex := utils.MomentBinarySearch(history, from)
+	endIndex := utils.MomentBinarySearch(history, to)
 
-	requestedRange := m.History[ISIN(symbol)][startIndex:endIndex]
-	if len(requestedRange) == 0 {
+	if endIndex <= startIndex {
 		return nil
 	}
+	requestedRange := make([]models.MFPriceData, endIndex-startIndex)
+	copy(requestedRange, history[startIndex:endIndex])
+
 	startPrice := requestedRange[0].Price
 	for i := range requestedRange {
 		requestedRange[i].PercentChange = ((requestedRange[i].Price - startPrice) / startPrice) * 100
@@ -104,12 +147,13 @@ func (m *MFTrendCache) GetMFGrowthComparison(symbols []string, from, to time.Tim
 		}
 	}
 
+	isinToFundName := *m.isinToFundName.Load()
 	response := make([]map[string]interface{}, len(growthMap))
 	index := 0
 	for timeStamp, mapSymbolToPrice := range growthMap {
 		response[index] = make(map[string]interface{})
 		for s, p := range mapSymbolToPrice {
-			fundName := m.ISINToFundName[ISIN(s)].String()
+			fundName := isinToFundName[ISIN(s)].String()
 			response[index][fundName] = p
 		}
 		response[index]["time"] = timeStamp
@@ -149,51 +193,59 @@ func (m *MFTrendCache) GetMFGrowthComparison(symbols []string, from, to time.Tim
 // 	return nil
 // }
 
-func persistMFInFile(symbol string, trend interface{}) error {
-	fileContent, err := json.Marshal(trend)
-	if err != nil {
-		return errors.Wrap(err, "unable to persist MF trade file")
-	}
-	if _, err := os.Stat("./data/trends/MF/"); os.IsNotExist(err) {
-		if err := os.MkdirAll("./data/trends/MF/", os.ModePerm); err != nil {
-			return errors.Wrap(err, "unable to create MF trends directory")
-		}
-	}
-	fileName := fmt.Sprintf("./data/trends/MF/%s.json", symbol)
-	return os.WriteFile(fileName, fileContent, os.ModePerm)
+// BuildMFPriceHistoryCache fetches only the NAVs missing since the last cached one
+// for every fund, the full history is fetched for funds not cached yet
+func (m *MFTrendCache) BuildMFPriceHistoryCache(allFunds map[FundName]ISIN, progress RefreshProgress) RefreshReport {
+	return m.BuildMFPriceHistoryCacheSince(allFunds, time.Time{}, progress)
 }
 
-// persist call comes from here for mf
-func (m *MFTrendCache) BuildMFPriceHistoryCache(allFunds map[FundName]ISIN) error {
-	var errorList []string
+// BuildMFPriceHistoryCacheSince is BuildMFPriceHistoryCache refetching every NAV from since
+// onwards, cached NAVs in that window are replaced. A zero since refreshes incrementally.
+func (m *MFTrendCache) BuildMFPriceHistoryCacheSince(allFunds map[FundName]ISIN, since time.Time, progress RefreshProgress) RefreshReport {
+	tasks := make([]refreshTask, 0, len(allFunds))
 	for name, isin := range allFunds {
-		history, err := MC.GetMFHistoryFromMoneyControll(string(isin))
-		if err != nil {
-			fmt.Printf("error fetching history for MF %s, err:%s", name, err.Error())
-			errorList = append(errorList, fmt.Sprintf("error fetching history for %s, err:%s", isin, err.Error()))
-			continue
-		}
-		m.History[isin] = history
-		err = persistMFInFile(string(isin), history)
-		if err != nil {
-			fmt.Printf("error persisting history for %s, err:%s", isin, err.Error())
-			errorList = append(errorList, fmt.Sprintf("error persisting history for %s, err:%s", isin, err.Error()))
-			continue
-		}
-	}
-	if len(errorList) == 0 {
-		return nil
+		tasks = append(tasks, refreshTask{symbol: string(isin), name: name.String()})
 	}
-	return errors.New(strings.Join(errorList, "\n"))
+	return runRefresh(tasks, m.concurrency, progress, func(isin string) (int, error) {
+		return m.refreshFund(ISIN(isin), since)
+	})
 }
 
-func buildFundsCacheFromFile(symbol ISIN) ([]models.MFPriceData, error) {
-	fileName := fmt.Sprintf("./data/trends/MF/%s.json", symbol)
-	fileContent, err := os.ReadFile(fileName)
+func (m *MFTrendCache) refreshFund(isin ISIN, since time.Time) (int, error) {
+	existing := m.history.get(isin)
+
+	from := historyStart
+	if len(existing) > 0 {
+		from = existing[len(existing)-1].Timestamps
+	}
+	if !since.IsZero() && since.Before(from) {
+		from = since
+	}
+	history, err := m.provider.GetMFHistory(string(isin), from, time.Now())
+	if err != nil {
+		if len(existing) > 0 && errors.Is(err, trackers.ErrNoData) {
+			// no NAV declared since the last refresh
+			return 0, errUpToDate
+		}
+		return 0, errors.Wrap(err, "error fetching history")
+	}
+
+	m.history.mu.Lock()
+	defer m.history.mu.Unlock()
+
+	existing = m.history.get(isin)
+	merged := mergeByDay(existing, history)
+	if len(merged) == len(existing) && isExtension(existing, merged, sameNAV) {
+		return 0, errUpToDate
+	}
+	m.history.publish(isin, merged)
+	err = saveTrend(m.store, string(isin), existing, merged, sameNAV)
 	if err != nil {
-		return nil, err
+		return 0, errors.Wrap(err, "error persisting history")
 	}
-	trend := []models.MFPriceData{}
-	err = json.Unmarshal(fileContent, &trend)
-	return trend, err
+	return len(merged) - len(existing), nil
+}
+
+func sameNAV(a, b models.MFPriceData) bool {
+	return a.Timestamps.Equal(b.Timestamps) && a.Price == b.Price
 }
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func TestMFTrendCacheConcurrentAccess(t *testing.T) {
+	// meaningful with the race detector, go test -race
+	var navs []models.MFPriceData
+	for d := 1; d <= 28; d++ {
+		navs = append(navs, models.MFPriceData{Timestamps: day(d), Price: float32(10 + d)})
+	}
+	provider := &fakeProvider{navs: navs[:10]}
+	funds := map[service.ISIN]service.FundName{"INF179K01UT0": "HDFC Flexi Cap", "INF846K01DP8": "Axis Bluechip"}
+	cache := service.GetMFTrendCache(slog.Default(), funds, provider, t.TempDir(), 4)
+
+	allFunds := make(map[service.FundName]service.ISIN)
+	for isin, name := range funds {
+		allFunds[name] = isin
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				cache.GetPriceMFTrendInTimeRange("INF179K01UT0", day(1), day(28))
+				cache.GetMFGrowthComparison([]string{"INF179K01UT0", "INF846K01DP8"}, day(1), day(28))
+			}
+		}()
+	}
+
+	for i := 10; i <= len(navs); i += 6 {
+		provider.mu.Lock()
+		provider.navs = navs[:i]
+		provider.mu.Unlock()
+		report := cache.BuildMFPriceHistoryCache(allFunds, nil)
+		assert.Nil(t, report.Err())
+		cache.SetFundNames(funds)
+	}
+	close(done)
+	readers.Wait()
+
+	assert.Len(t, cache.GetHistory("INF179K01UT0"), len(navs))
+	comparison := cache.GetMFGrowthComparison([]string{"INF179K01UT0", "INF846K01DP8"}, day(1), day(28))
+	assert.Contains(t, comparison[0], "HDFC Flexi Cap")
+}
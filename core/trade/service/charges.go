@@ -0,0 +1,226 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/pkg/errors"
+)
+
+// products of an equity trade, they are charged differently
+const (
+	ProductDelivery = "delivery"
+	ProductIntraday = "intraday"
+)
+
+// Charges are the brokerage and statutory charges of a trade
+type Charges struct {
+	Brokerage       float64 `json:"brokerage"`
+	STT             float64 `json:"stt"`
+	ExchangeCharges float64 `json:"exchange_charges"`
+	SEBIFees        float64 `json:"sebi_fees"`
+	StampDuty       float64 `json:"stamp_duty"`
+	GST             float64 `json:"gst"`
+}
+
+// Total is the sum of every charge
+func (c Charges) Total() float64 {
+	return c.Brokerage + c.STT + c.ExchangeCharges + c.SEBIFees + c.StampDuty + c.GST
+}
+
+func (c Charges) add(o Charges) Charges {
+	return Charges{
+		Brokerage:       c.Brokerage + o.Brokerage,
+		STT:             c.STT + o.STT,
+		ExchangeCharges: c.ExchangeCharges + o.ExchangeCharges,
+		SEBIFees:        c.SEBIFees + o.SEBIFees,
+		StampDuty:       c.StampDuty + o.StampDuty,
+		GST:             c.GST + o.GST,
+	}
+}
+
+// Scale returns the charges of a part f of the trade
+func (c Charges) Scale(f float64) Charges {
+	return Charges{
+		Brokerage:       c.Brokerage * f,
+		STT:             c.STT * f,
+		ExchangeCharges: c.ExchangeCharges * f,
+		SEBIFees:        c.SEBIFees * f,
+		StampDuty:       c.StampDuty * f,
+		GST:             c.GST * f,
+	}
+}
+
+// chargesVersion is the rates effective from a day
+type chargesVersion struct {
+	from  time.Time
+	rates []config.ChargeRatesConfig
+}
+
+// ChargeRates is the versioned rate table trades are charged by
+type ChargeRates struct {
+	// versions are oldest first
+	versions []chargesVersion
+}
+
+// NewChargeRates validates the configured versions of the rate table
+func NewChargeRates(versions []config.ChargesConfig) (*ChargeRates, error) {
+	r := &ChargeRates{}
+	for i, version := range versions {
+		from, err := time.Parse(time.DateOnly, strings.TrimSpace(version.EffectiveFrom))
+		if err != nil {
+			return nil, errors.Errorf("charges %d has an invalid effective_from %q, expected YYYY-MM-DD", i+1, version.EffectiveFrom)
+		}
+		for _, rates := range version.Rates {
+			switch strings.ToLower(rates.Product) {
+			case "", ProductDelivery, ProductIntraday:
+			default:
+				return nil, errors.Errorf("charges from %s have an unknown product %q, expected delivery or intraday", version.EffectiveFrom, rates.Product)
+			}
+			for _, rate := range []float64{rates.BrokeragePercent, rates.BrokerageMax, rates.STTBuyPercent, rates.STTSellPercent,
+				rates.ExchangePercent, rates.SEBIPerCrore, rates.StampDutyBuyPercent, rates.GSTPercent} {
+				if rate < 0 {
+					return nil, errors.Errorf("charges from %s have a negative rate", version.EffectiveFrom)
+				}
+			}
+		}
+		r.versions = append(r.versions, chargesVersion{from: from, rates: version.Rates})
+	}
+	sort.SliceStable(r.versions, func(i, j int) bool { return r.versions[i].from.Before(r.versions[j].from) })
+	for i := 1; i < len(r.versions); i++ {
+		if r.versions[i].from.Equal(r.versions[i-1].from) {
+			return nil, errors.Errorf("charges are effective from %s twice", r.versions[i].from.Format(time.DateOnly))
+		}
+	}
+	return r, nil
+}
+
+// rates returns the rates of a trade of product, the trade is free of charges when none match
+func (r *ChargeRates) rates(trade EquityTrade, product string) (config.ChargeRatesConfig, bool) {
+	date := time.Date(trade.TradeDate.Year(), trade.TradeDate.Month(), trade.TradeDate.Day(), 0, 0, 0, 0, time.UTC)
+	for i := len(r.versions) - 1; i >= 0; i-- {
+		if r.versions[i].from.After(date) {
+			continue
+		}
+		for _, rates := range r.versions[i].rates {
+			if matches(rates.Exchange, trade.Exchange) && matches(rates.Segment, trade.Segment) && matches(rates.Product, product) {
+				return rates, true
+			}
+		}
+		return config.ChargeRatesConfig{}, false
+	}
+	return config.ChargeRatesConfig{}, false
+}
+
+func matches(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// Apply sets the charges of trades, which must be of one symbol. The quantity bought and sold
+// on the same day by an account is squared off intraday, it is split between the trades of
+// either side by quantity and the rest of them is charged as delivery. Brokerage is capped per
+// order and product and split between its trades by value. Charges are not rounded as
+// contract notes do.
+func (r *ChargeRates) Apply(trades []EquityTrade) {
+	if r == nil || len(r.versions) == 0 {
+		return
+	}
+	type day struct {
+		account string
+		date    string
+	}
+	// quantity bought and sold per day
+	sides := make(map[day][2]float64)
+	side := func(trade EquityTrade) int {
+		if trade.TradeType == "sell" {
+			return 1
+		}
+		return 0
+	}
+	for _, trade := range trades {
+		key := day{trade.Account, trade.TradeDate.Format(time.DateOnly)}
+		s := sides[key]
+		s[side(trade)] += trade.Quantity
+		sides[key] = s
+	}
+
+	// part is the share of a trade charged as product
+	type part struct {
+		product string
+		share   float64
+	}
+	parts := make([][]part, len(trades))
+	for i, trade := range trades {
+		s := sides[day{trade.Account, trade.TradeDate.Format(time.DateOnly)}]
+		intraday := 0.0
+		if total := s[side(trade)]; total > 0 {
+			intraday = min(s[0], s[1]) / total
+		}
+		if intraday > 0 {
+			parts[i] = append(parts[i], part{ProductIntraday, intraday})
+		}
+		if intraday < 1 {
+			parts[i] = append(parts[i], part{ProductDelivery, 1 - intraday})
+		}
+	}
+
+	type order struct {
+		value     float64
+		brokerage float64
+	}
+	orders := make(map[string]*order)
+	orderKey := func(i int, product string) string {
+		if trades[i].OrderId == "" {
+			return "trade/" + strconv.Itoa(i) + "/" + product
+		}
+		return trades[i].Account + "/" + trades[i].OrderId + "/" + product
+	}
+	for i, trade := range trades {
+		for _, part := range parts[i] {
+			rates, ok := r.rates(trade, part.product)
+			if !ok {
+				continue
+			}
+			key := orderKey(i, part.product)
+			if _, ok := orders[key]; !ok {
+				orders[key] = &order{}
+			}
+			value := trade.Price * trade.Quantity * part.share
+			orders[key].value += value
+			orders[key].brokerage += value * rates.BrokeragePercent / 100
+			if rates.BrokerageMax > 0 && orders[key].brokerage > rates.BrokerageMax {
+				orders[key].brokerage = rates.BrokerageMax
+			}
+		}
+	}
+
+	for i := range trades {
+		trade := &trades[i]
+		var charges Charges
+		for _, part := range parts[i] {
+			rates, ok := r.rates(*trade, part.product)
+			if !ok {
+				continue
+			}
+			value := trade.Price * trade.Quantity * part.share
+			var c Charges
+			if o := orders[orderKey(i, part.product)]; o.value > 0 {
+				c.Brokerage = o.brokerage * value / o.value
+			}
+			if trade.TradeType == "sell" {
+				c.STT = value * rates.STTSellPercent / 100
+			} else {
+				c.STT = value * rates.STTBuyPercent / 100
+				c.StampDuty = value * rates.StampDutyBuyPercent / 100
+			}
+			c.ExchangeCharges = value * rates.ExchangePercent / 100
+			c.SEBIFees = value * rates.SEBIPerCrore / 1e7
+			c.GST = (c.Brokerage + c.ExchangeCharges + c.SEBIFees) * rates.GSTPercent / 100
+			charges = charges.add(c)
+		}
+		trade.Charges = charges
+	}
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func TestTradeCharges(t *testing.T) {
+	delivery := config.ChargeRatesConfig{
+		Segment: "EQ", Product: service.ProductDelivery, STTBuyPercent: 0.1, STTSellPercent: 0.1,
+		ExchangePercent: 0.00322, SEBIPerCrore: 10, StampDutyBuyPercent: 0.015, GSTPercent: 18,
+	}
+	intraday := config.ChargeRatesConfig{
+		Segment: "EQ", Product: service.ProductIntraday, BrokeragePercent: 0.03, BrokerageMax: 20, STTSellPercent: 0.025,
+		ExchangePercent: 0.00297, SEBIPerCrore: 10, StampDutyBuyPercent: 0.003, GSTPercent: 18,
+	}
+	revised := delivery
+	revised.ExchangePercent = 0.00297
+	rates, err := service.NewChargeRates([]config.ChargesConfig{
+		{EffectiveFrom: "2024-10-01", Rates: []config.ChargeRatesConfig{revised, intraday}},
+		{EffectiveFrom: "2024-01-01", Rates: []config.ChargeRatesConfig{delivery, intraday}},
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2024.csv",
+		"INFY,INE009A01021,2024-06-03,NSE,EQ,EQ,buy,false,10,1500,1,101,\n"+
+			"INFY,INE009A01021,2024-11-04,NSE,EQ,EQ,sell,false,10,1800,2,102,\n"+
+			"INFY,INE009A01021,2024-11-05,NSE,EQ,EQ,buy,false,60,1000,3,103,\n"+
+			"INFY,INE009A01021,2024-11-05,NSE,EQ,EQ,buy,false,40,1000,4,103,\n"+
+			"INFY,INE009A01021,2024-11-05,NSE,EQ,EQ,sell,false,100,1010,5,104,\n")
+	tradebook := getTradebookService(t, dir, "")
+	tradebook.SetChargeRates(rates)
+
+	trades := tradebook.GetAdjustedEquityTrades("INFY")
+	require.Len(t, trades, 5)
+	buy := trades[0].Charges
+	assert.InDelta(t, 15, buy.STT, 1e-9)
+	assert.InDelta(t, 0.483, buy.ExchangeCharges, 1e-9)
+	assert.InDelta(t, 0.015, buy.SEBIFees, 1e-9)
+	assert.InDelta(t, 2.25, buy.StampDuty, 1e-9)
+	assert.InDelta(t, 0.18*(0.483+0.015), buy.GST, 1e-9)
+	sell := trades[1].Charges
+	assert.InDelta(t, 18*0.0297, sell.ExchangeCharges, 1e-9)
+	assert.Zero(t, sell.StampDuty)
+
+	// bought and sold on the same day, the brokerage of an order is capped and split by value
+	assert.InDelta(t, 12, trades[2].Charges.Brokerage, 1e-9)
+	assert.InDelta(t, 8, trades[3].Charges.Brokerage, 1e-9)
+	assert.Zero(t, trades[2].Charges.STT)
+	assert.InDelta(t, 20, trades[4].Charges.Brokerage, 1e-9)
+	assert.InDelta(t, 25.25, trades[4].Charges.STT, 1e-9)
+
+	pnl, err := tradebook.GetSymbolPnL("INFY", nil)
+	require.NoError(t, err)
+	require.Len(t, pnl.ClosedLots, 3)
+	lot := pnl.ClosedLots[0]
+	assert.InDelta(t, 15000+buy.Total(), lot.Cost, 1e-9)
+	assert.InDelta(t, 18000-sell.Total(), lot.Proceeds, 1e-9)
+	assert.InDelta(t, 3000-buy.Total()-sell.Total(), lot.RealisedGain, 1e-9)
+
+	var total float64
+	for _, trade := range trades {
+		total += trade.Charges.Total()
+	}
+	breakdown, err := tradebook.GetEqBreakdown("INFY")
+	require.NoError(t, err)
+	assert.InDelta(t, total, breakdown.Charges.Total(), 1e-9)
+	assert.InDelta(t, pnl.Realised, breakdown.RealisedGain, 1e-9)
+	assert.Equal(t, trades[4].Charges, breakdown.TradeHistory[4].Charges)
+
+	// only the quantity squared off on the day is intraday, the rest of the buy is delivery
+	dir = t.TempDir()
+	writeTradeFile(t, dir, "2024.csv",
+		"TCS,INE467B01029,2024-11-06,NSE,EQ,EQ,buy,false,10,1000,6,105,\n"+
+			"TCS,INE467B01029,2024-11-06,NSE,EQ,EQ,sell,false,4,1010,7,106,\n")
+	tradebook = getTradebookService(t, dir, "")
+	tradebook.SetChargeRates(rates)
+	trades = tradebook.GetAdjustedEquityTrades("TCS")
+	require.Len(t, trades, 2)
+	partial := trades[0].Charges
+	assert.InDelta(t, 4000*0.0003, partial.Brokerage, 1e-9)
+	assert.InDelta(t, 6000*0.001, partial.STT, 1e-9)
+	assert.InDelta(t, 4000*0.00003+6000*0.00015, partial.StampDuty, 1e-9)
+	assert.InDelta(t, 10000*0.0000297, partial.ExchangeCharges, 1e-9)
+	squaredOff := trades[1].Charges
+	assert.InDelta(t, 4040*0.0003, squaredOff.Brokerage, 1e-9)
+	assert.InDelta(t, 4040*0.00025, squaredOff.STT, 1e-9)
+
+	_, err = service.NewChargeRates([]config.ChargesConfig{{EffectiveFrom: "2024-01-01", Rates: []config.ChargeRatesConfig{{Product: "mtf"}}}})
+	assert.Error(t, err)
+	_, err = service.NewChargeRates([]config.ChargesConfig{{EffectiveFrom: "01/01/2024"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// kinds of corporate actions that change the number of shares held
+const (
+	ActionSplit = "split"
+	ActionBonus = "bonus"
+)
+
+// CorporateAction is a split or bonus of a symbol. The ratio of a split is old:new
+// shares, e.g. 1:5 when a share of face value 10 splits into five of face value 2,
+// the ratio of a bonus is bonus:held shares, e.g. 1:2 for one bonus share per two held.
+type CorporateAction struct {
+	Symbol ScriptName `json:"symbol"`
+	Type   string     `json:"type"`
+	// ExDate is the first day the shares trade at the adjusted price
+	ExDate time.Time `json:"ex_date"`
+	Ratio  string    `json:"ratio"`
+	// Factor is the number of shares held after the action per share held before
+	Factor float64 `json:"factor"`
+}
+
+// CorporateActions adjusts trades and candles before the ex-date of splits and bonuses
+// to the share count after them. A nil CorporateActions adjusts nothing.
+type CorporateActions struct {
+	actions map[ScriptName][]CorporateAction
+}
+
+// LoadCorporateActions reads the splits and bonuses listed in a YAML or CSV file,
+// a CSV file has the columns symbol, type, ex_date and ratio
+func LoadCorporateActions(fileName string) (*CorporateActions, error) {
+	records, err := ReadRecordsFile(fileName, []string{"symbol", "type", "ex_date", "ratio"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read corporate actions")
+	}
+	actions := make([]CorporateAction, 0, len(records))
+	for i, record := range records {
+		action, err := parseCorporateAction(record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "corporate action %d of %s", i+1, fileName)
+		}
+		actions = append(actions, action)
+	}
+	return NewCorporateActions(actions), nil
+}
+
+func parseCorporateAction(record map[string]string) (CorporateAction, error) {
+	exDate, err := time.Parse(time.DateOnly, strings.TrimSpace(record["ex_date"]))
+	if err != nil {
+		return CorporateAction{}, errors.Errorf("invalid ex_date %q, expected YYYY-MM-DD", record["ex_date"])
+	}
+	a, b, err := parseRatio(record["ratio"])
+	if err != nil {
+		return CorporateAction{}, err
+	}
+
+	action := CorporateAction{
+		Symbol: ScriptName(strings.ToUpper(strings.TrimSpace(record["symbol"]))),
+		ExDate: exDate,
+		Ratio:  record["ratio"],
+	}
+	switch strings.ToLower(strings.TrimSpace(record["type"])) {
+	case ActionSplit:
+		action.Type, action.Factor = ActionSplit, b/a
+	case ActionBonus:
+		action.Type, action.Factor = ActionBonus, (a+b)/b
+	default:
+		return CorporateAction{}, errors.Errorf("unknown corporate action %q, expected split or bonus", record["type"])
+	}
+	return action, nil
+}
+
+// NewCorporateActions indexes actions by symbol
+func NewCorporateActions(actions []CorporateAction) *CorporateActions {
+	c := &CorporateActions{actions: make(map[ScriptName][]CorporateAction)}
+	for _, action := range actions {
+		c.actions[action.Symbol] = append(c.actions[action.Symbol], action)
+	}
+	for symbol := range c.actions {
+		sort.SliceStable(c.actions[symbol], func(i, j int) bool {
+			return c.actions[symbol][i].ExDate.Before(c.actions[symbol][j].ExDate)
+		})
+	}
+	return c
+}
+
+// Get returns the actions of symbol ordered by ex-date
+func (c *CorporateActions) Get(symbol ScriptName) []CorporateAction {
+	if c == nil {
+		return nil
+	}
+	return c.actions[symbol]
+}
+
+// Factor is the number of shares held today per share of symbol held on date
+func (c *CorporateActions) Factor(symbol ScriptName, date time.Time) float64 {
+	return c.factorUntil(symbol, date, time.Time{})
+}
+
+// factorUntil is Factor counting only the actions with an ex-date before until, a zero
+// until counts every action. Dates are compared as IST trading days, ex-dates are
+// read at UTC midnight while candles and trades may be stamped at IST midnight.
+func (c *CorporateActions) factorUntil(symbol ScriptName, date, until time.Time) float64 {
+	factor := 1.0
+	for _, action := range c.Get(symbol) {
+		if !until.IsZero() && !beforeDay(action.ExDate, until) {
+			break
+		}
+		if beforeDay(date, action.ExDate) {
+			factor *= action.Factor
+		}
+	}
+	return factor
+}
+
+// beforeDay reports whether the trading day of a is before the trading day of b
+func beforeDay(a, b time.Time) bool {
+	return utils.DateKey(a) < utils.DateKey(b)
+}
+
+// AdjustTrade returns trade with its quantity and price in shares held today, the
+// value of the trade is unchanged. A trade moved to a successor symbol is adjusted
+// for the actions of the symbol it was traded as too, up to the day the successor
+// took over, later actions of that symbol do not touch the successor's shares.
+func (c *CorporateActions) AdjustTrade(trade EquityTrade) EquityTrade {
+	factor := c.Factor(ScriptName(trade.Symbol), trade.TradeDate)
+	if trade.OriginalSymbol != "" && trade.OriginalSymbol != trade.Symbol {
+		factor *= c.factorUntil(ScriptName(trade.OriginalSymbol), trade.TradeDate, trade.SymbolChangedOn)
+	}
+	if factor == 1 {
+		return trade
+	}
+	trade.Quantity *= factor
+	trade.Price /= factor
+	return trade
+}
+
+// AdjustCandles adjusts in place the prices and volumes of the candles of symbol
+// before every ex-date, so the series has no jumps from splits and bonuses
+func (c *CorporateActions) AdjustCandles(symbol ScriptName, candles []models.EquityPriceData) {
+	if len(c.Get(symbol)) == 0 {
+		return
+	}
+	for i := range candles {
+		factor := float32(c.Factor(symbol, candles[i].Timestamps))
+		if factor == 1 {
+			continue
+		}
+		candles[i].Open /= factor
+		candles[i].Close /= factor
+		candles[i].High /= factor
+		candles[i].Low /= factor
+		candles[i].Volume *= factor
+	}
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"log/slog"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+)
+
+func TestLoadCorporateActions(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "actions.yaml", `
+- symbol: infy
+  type: bonus
+  ex_date: 2024-01-10
+  ratio: "1:1"
+- symbol: INFY
+  type: split
+  ex_date: 2024-01-05
+  ratio: "1:5"
+`)
+	writeTradeFile(t, dir, "actions.csv", "Symbol,Type,Ex Date,Ratio\nINFY,bonus,2024-01-10,1:1\nINFY,split,2024-01-05,1:5\n")
+
+	for _, name := range []string{"actions.yaml", "actions.csv"} {
+		actions, err := service.LoadCorporateActions(path.Join(dir, name))
+		require.NoError(t, err, name)
+		loaded := actions.Get("INFY")
+		require.Len(t, loaded, 2, name)
+		assert.Equal(t, service.ActionSplit, loaded[0].Type)
+		assert.Equal(t, 5.0, loaded[0].Factor)
+		assert.Equal(t, 2.0, loaded[1].Factor)
+		assert.Equal(t, 10.0, actions.Factor("INFY", day(4)))
+		assert.Equal(t, 2.0, actions.Factor("INFY", day(5)))
+		assert.Equal(t, 1.0, actions.Factor("INFY", day(10)))
+	}
+
+	writeTradeFile(t, dir, "bad.yaml", "- symbol: INFY\n  type: merger\n  ex_date: 2024-01-10\n  ratio: \"1:1\"\n")
+	_, err := service.LoadCorporateActions(path.Join(dir, "bad.yaml"))
+	assert.Error(t, err)
+}
+
+func TestCorporateActionsAdjustTradesAndCandles(t *testing.T) {
+	actions := service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "INFY", Type: service.ActionSplit, ExDate: day(5), Ratio: "1:5", Factor: 5},
+	})
+
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2024.csv",
+		"INFY,INE009A01021,2024-01-02,NSE,EQ,EQ,buy,false,10,1500,1,101,2024-01-02T09:20:11\n"+
+			"INFY,INE009A01021,2024-01-08,NSE,EQ,EQ,sell,false,20,310,2,102,2024-01-08T10:00:00\n")
+	tradebook := getTradebookService(t, dir, "")
+	tradebook.SetCorporateActions(actions)
+
+	breakdown, err := tradebook.GetEqBreakdown("INFY")
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, breakdown.NetQuantity)
+	assert.Equal(t, 15000.0, breakdown.TotalBuyValue)
+	require.Len(t, breakdown.TradeHistory, 2)
+	assert.Equal(t, service.TradeRecord{Date: "2024-01-02", Price: 300, Quantity: 50, Type: "buy", RawPrice: 1500, RawQuantity: 10}, breakdown.TradeHistory[0])
+	assert.Equal(t, service.TradeRecord{Date: "2024-01-08", Price: 310, Quantity: 20, Type: "sell"}, breakdown.TradeHistory[1])
+	// the tradebook keeps the trades as traded
+	assert.Equal(t, 10.0, tradebook.GetEquityTradebook().EquityTradebook["INFY"][0].Quantity)
+
+	provider := &fakeProvider{candles: []models.EquityPriceData{
+		{Timestamps: day(4), Close: 1500, Volume: 100},
+		{Timestamps: day(5), Close: 300, Volume: 500},
+		{Timestamps: day(6), Close: 330, Volume: 400},
+		{Timestamps: day(7), Close: 320, Volume: 400},
+	}}
+	cache := service.GetEquityTrendCache(slog.Default(), nil, provider, t.TempDir(), 1)
+	cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil)
+	cache.SetCorporateActions(actions)
+
+	trend := cache.GetPriceTrendInTimeRange("INFY", day(4), day(7))
+	require.Len(t, trend, 3)
+	assert.Equal(t, float32(300), trend[0].Close)
+	assert.Equal(t, float32(500), trend[0].Volume)
+	assert.Equal(t, float32(0), trend[1].PercentChange)
+	assert.Equal(t, float32(10), trend[2].PercentChange)
+	assert.Equal(t, float32(1500), cache.GetHistory("INFY")[0].Close)
+}
+
+func TestCorporateActionsAdjustMovedTrade(t *testing.T) {
+	actions := service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "PARENT", Type: service.ActionBonus, ExDate: day(5), Ratio: "1:1", Factor: 2},
+		{Symbol: "PARENT", Type: service.ActionSplit, ExDate: day(20), Ratio: "1:5", Factor: 5},
+	})
+	trade := service.EquityTrade{Symbol: "CHILD", OriginalSymbol: "PARENT", SymbolChangedOn: day(10), TradeDate: day(2), Quantity: 10, Price: 100}
+
+	// the split after the successor took over is not the successor's
+	adjusted := actions.AdjustTrade(trade)
+	assert.Equal(t, 20.0, adjusted.Quantity)
+	assert.Equal(t, 50.0, adjusted.Price)
+}
+
+func TestCorporateActionsAdjustISTCandles(t *testing.T) {
+	actions := service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "INFY", Type: service.ActionSplit, ExDate: day(5), Ratio: "1:5", Factor: 5},
+	})
+	istDay := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, utils.IST) }
+
+	// bhavcopy candles are stamped at IST midnight, before the UTC midnight of the ex-date
+	candles := []models.EquityPriceData{
+		{Timestamps: istDay(4), Close: 1500, Volume: 100},
+		{Timestamps: istDay(5), Close: 300, Volume: 500},
+	}
+	actions.AdjustCandles("INFY", candles)
+	assert.Equal(t, float32(300), candles[0].Close)
+	assert.Equal(t, float32(500), candles[0].Volume)
+	assert.Equal(t, float32(300), candles[1].Close)
+	assert.Equal(t, float32(500), candles[1].Volume)
+
+	assert.Equal(t, 5.0, actions.Factor("INFY", istDay(4)))
+	assert.Equal(t, 1.0, actions.Factor("INFY", istDay(5)))
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// Dividend is a dividend, or IDCW payout of a fund, paid on a holding
+type Dividend struct {
+	Account string     `json:"account"`
+	Symbol  ScriptName `json:"symbol"`
+	ISIN    string     `json:"isin"`
+	// RecordDate is the record, or ex, date and PayDate the day it was paid, either may be unset
+	RecordDate time.Time `json:"record_date"`
+	PayDate    time.Time `json:"pay_date"`
+	Quantity   float64   `json:"quantity"`
+	PerShare   float64   `json:"per_share"`
+	// Amount is the amount paid out, after TDS when the statement deducts it
+	Amount float64 `json:"amount"`
+	TDS    float64 `json:"tds"`
+	File   string  `json:"file"`
+}
+
+// Date is the day the dividend was paid, or its record date when the pay date is unknown
+func (d Dividend) Date() time.Time {
+	if !d.PayDate.IsZero() {
+		return d.PayDate
+	}
+	return d.RecordDate
+}
+
+// dividend columns as named by dividend ledgers, the amount paid is colAmount
+const (
+	colRecordDate = "record_date"
+	colPayDate    = "pay_date"
+	colPerShare   = "per_share"
+	colTDS        = "tds"
+)
+
+// readDividends reads the Zerodha dividend statements (CSV) and dividend ledgers (YAML) in dir.
+// Files and rows that cannot be read are recorded on the file and skipped, as for trades.
+func readDividends(dir, account string) ([]Dividend, []TradeFile, error) {
+	files, err := tradeFiles(dir)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read dividends directory")
+	}
+	var (
+		dividends []Dividend
+		read      []TradeFile
+	)
+	for _, file := range files {
+		fn := func(row tradebookRow) error {
+			dividend, err := parseDividend(row)
+			if err != nil {
+				return err
+			}
+			dividend.Account, dividend.File = account, file.Name
+			dividends = append(dividends, dividend)
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(file.Name)) {
+		case ".csv":
+			readTradebookFile(&file, AssetDividends, fn)
+		case ".yaml", ".yml":
+			readDividendLedger(&file, fn)
+		default:
+			continue
+		}
+		read = append(read, file)
+	}
+	sortDividends(dividends)
+	return dividends, read, nil
+}
+
+// readDividendLedger calls fn with every record of a YAML dividend ledger as a row,
+// numbered by its position in the ledger
+func readDividendLedger(file *TradeFile, fn func(row tradebookRow) error) {
+	records, err := ReadRecordsFile(file.Name, nil)
+	if err != nil {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: err.Error()})
+		return
+	}
+	for i, record := range records {
+		header := make([]string, 0, len(record))
+		values := make([]string, 0, len(record))
+		for name, value := range record {
+			header = append(header, name)
+			values = append(values, value)
+		}
+		row := tradebookRow{file: file.Name, line: i + 1, columns: dividendLedger.columns(header), record: values}
+		file.readRow(dividendLedger, row, fn)
+	}
+}
+
+func parseDividend(row tradebookRow) (Dividend, error) {
+	number := func(column string) (float64, error) {
+		if row.get(column) == "" {
+			return 0, nil
+		}
+		return row.float(column)
+	}
+	day := func(column string) (time.Time, error) {
+		if row.get(column) == "" {
+			return time.Time{}, nil
+		}
+		return row.day(column)
+	}
+
+	dividend := Dividend{
+		Symbol: ScriptName(strings.ToUpper(row.get(colSymbol))),
+		ISIN:   strings.ToUpper(row.get(colISIN)),
+	}
+	if dividend.Symbol == "" && dividend.ISIN == "" {
+		return Dividend{}, errIgnoredRow
+	}
+	var err error
+	if dividend.RecordDate, err = day(colRecordDate); err != nil {
+		return Dividend{}, err
+	}
+	if dividend.PayDate, err = day(colPayDate); err != nil {
+		return Dividend{}, err
+	}
+	if dividend.Date().IsZero() && dividend.ISIN == "" {
+		// e.g. the total row of a statement
+		return Dividend{}, errIgnoredRow
+	}
+	if dividend.Date().IsZero() {
+		return Dividend{}, row.errorf(colRecordDate, "record_date or pay_date is required")
+	}
+	if dividend.Quantity, err = number(colQuantity); err != nil {
+		return Dividend{}, err
+	}
+	if dividend.PerShare, err = number(colPerShare); err != nil {
+		return Dividend{}, err
+	}
+	if dividend.Amount, err = number(colAmount); err != nil {
+		return Dividend{}, err
+	}
+	if dividend.TDS, err = number(colTDS); err != nil {
+		return Dividend{}, err
+	}
+	if row.get(colAmount) == "" {
+		if dividend.PerShare == 0 || dividend.Quantity == 0 {
+			return Dividend{}, row.errorf(colAmount, "amount, or per_share and quantity, is required")
+		}
+		dividend.Amount = dividend.PerShare*dividend.Quantity - dividend.TDS
+	}
+	return dividend, nil
+}
+
+func sortDividends(dividends []Dividend) {
+	sort.SliceStable(dividends, func(i, j int) bool {
+		return dividends[i].Date().Before(dividends[j].Date())
+	})
+}
+
+// DividendReport totals the dividends paid between two dates
+type DividendReport struct {
+	Account   string            `json:"account"`
+	Total     float64           `json:"total"`
+	TDS       float64           `json:"tds"`
+	ByYear    []DividendTotal   `json:"by_year"`
+	ByHolding []DividendHolding `json:"by_holding"`
+	Dividends []Dividend        `json:"dividends"`
+}
+
+// DividendTotal is the dividends paid in a financial year
+type DividendTotal struct {
+	FinancialYear string  `json:"financial_year"`
+	Amount        float64 `json:"amount"`
+	TDS           float64 `json:"tds"`
+}
+
+// DividendHolding is the dividends paid on a holding
+type DividendHolding struct {
+	Symbol   ScriptName `json:"symbol"`
+	ISIN     string     `json:"isin"`
+	Payouts  int        `json:"payouts"`
+	Amount   float64    `json:"amount"`
+	TDS      float64    `json:"tds"`
+	LastPaid time.Time  `json:"last_paid"`
+}
+
+// GetDividends reports the dividends of the viewed account paid from from to to
+func (t *TradebookService) GetDividends(from, to time.Time) DividendReport {
+	report := DividendReport{Account: t.account, Dividends: []Dividend{}}
+	years := make(map[string]*DividendTotal)
+	holdings := make(map[string]*DividendHolding)
+	for _, dividend := range t.books().dividends {
+		if dividend.Date().Before(from) || dividend.Date().After(to) {
+			continue
+		}
+		report.Dividends = append(report.Dividends, dividend)
+		report.Total += dividend.Amount
+		report.TDS += dividend.TDS
+
+		fy := utils.FinancialYear(dividend.Date())
+		if _, ok := years[fy]; !ok {
+			years[fy] = &DividendTotal{FinancialYear: fy}
+		}
+		years[fy].Amount += dividend.Amount
+		years[fy].TDS += dividend.TDS
+
+		key := dividend.ISIN
+		if key == "" {
+			key = dividend.Symbol.String()
+		}
+		holding, ok := holdings[key]
+		if !ok {
+			holding = &DividendHolding{Symbol: dividend.Symbol, ISIN: dividend.ISIN}
+			holdings[key] = holding
+		}
+		if holding.Symbol == "" {
+			holding.Symbol = dividend.Symbol
+		}
+		holding.Payouts++
+		holding.Amount += dividend.Amount
+		holding.TDS += dividend.TDS
+		holding.LastPaid = dividend.Date()
+	}
+
+	for _, total := range years {
+		report.ByYear = append(report.ByYear, *total)
+	}
+	sort.Slice(report.ByYear, func(i, j int) bool {
+		return report.ByYear[i].FinancialYear < report.ByYear[j].FinancialYear
+	})
+	for _, holding := range holdings {
+		report.ByHolding = append(report.ByHolding, *holding)
+	}
+	sort.Slice(report.ByHolding, func(i, j int) bool {
+		if report.ByHolding[i].Amount != report.ByHolding[j].Amount {
+			return report.ByHolding[i].Amount > report.ByHolding[j].Amount
+		}
+		return report.ByHolding[i].Symbol < report.ByHolding[j].Symbol
+	})
+	return report
+}
+
+// fundDividends returns the IDCW payouts of a fund from from to to
+func (t *TradebookService) fundDividends(isin ISIN, from, to time.Time) []Dividend {
+	var dividends []Dividend
+	for _, dividend := range t.books().dividends {
+		if dividend.ISIN == string(isin) && !dividend.Date().Before(from) && !dividend.Date().After(to) {
+			dividends = append(dividends, dividend)
+		}
+	}
+	return dividends
+}
@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func TestTradebookDividends(t *testing.T) {
+	mfDir, dividendsDir := t.TempDir(), t.TempDir()
+	writeTradeFile(t, mfDir, "coin.csv",
+		"HDFC BALANCED ADVANTAGE FUND - IDCW,INF179K01BB8,2023-01-02,,,,buy,false,1000,30,1,101,\n"+
+			"HDFC BALANCED ADVANTAGE FUND - IDCW,INF179K01BB8,2024-06-03,,,,buy,false,10,40,2,102,\n")
+	// Console statements open with the client details before the header
+	writeTradeFile(t, dividendsDir, "zerodha.csv",
+		"Client ID,AB1234\n"+
+			"Dividend Statement for 2023-04-01 to 2024-10-31\n"+
+			"\n"+
+			"Symbol,ISIN,Ex-Date,Quantity,Dividend Per Share,Net Dividend Amount\n"+
+			"ITC,INE154A01025,2024-02-08,100,6.25,625\n"+
+			"ITC,INE154A01025,2024-06-04,100,7.5,750\n"+
+			"ITC,INE154A01025,2024-09-31,100,7.5,750\n"+
+			"Total,,,,,1375\n")
+	writeTradeFile(t, dividendsDir, "broken.yaml", "- isin: [INF179K01BB8\n")
+	writeTradeFile(t, dividendsDir, "ledger.yaml", `
+- isin: INF179K01BB8
+  record_date: 2024-03-15
+  pay_date: 2024-03-18
+  quantity: 1000
+  per_share: 2
+  tds: 200
+`)
+
+	tradebook, err := service.GetTradebookService([]config.AccountConfig{{
+		Name:                           config.DefaultAccount,
+		MutualFundsTradeFilesDirectory: mfDir,
+		DividendsDirectory:             dividendsDir,
+	}}, slog.Default())
+	require.NoError(t, err)
+
+	report := tradebook.GetDividends(time.Time{}, time.Now())
+	require.Len(t, report.Dividends, 3)
+	assert.Equal(t, 625.0+750+1800, report.Total)
+	assert.Equal(t, 200.0, report.TDS)
+	assert.Equal(t, []service.DividendTotal{
+		{FinancialYear: "2023-24", Amount: 2425, TDS: 200},
+		{FinancialYear: "2024-25", Amount: 750},
+	}, report.ByYear)
+	require.Len(t, report.ByHolding, 2)
+	assert.Equal(t, "INF179K01BB8", report.ByHolding[0].ISIN)
+	assert.Equal(t, service.ScriptName("ITC"), report.ByHolding[1].Symbol)
+	assert.Equal(t, 2, report.ByHolding[1].Payouts)
+
+	fy2425 := tradebook.GetDividends(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Now())
+	assert.Equal(t, 750.0, fy2425.Total)
+
+	// bad rows and files are skipped and reported like those of trade files
+	files := tradebook.GetIngestionReport().DividendFiles
+	require.Len(t, files, 3)
+	broken, zerodha := files[0], files[2]
+	require.Len(t, broken.Errors, 1)
+	assert.Equal(t, 2, zerodha.Rows)
+	assert.Equal(t, 1, zerodha.HeaderRows)
+	assert.Equal(t, 3, zerodha.IgnoredRows)
+	require.Len(t, zerodha.Errors, 1)
+	assert.Contains(t, zerodha.Errors[0].Error(), `zerodha.csv:7 (record_date): invalid date "2024-09-31"`)
+
+	summary := tradebook.GetMFSummmary(time.Time{}, time.Now())
+	require.Len(t, summary, 1)
+	assert.Equal(t, 1800.0, summary[0].Dividends)
+}
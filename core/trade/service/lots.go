@@ -0,0 +1,418 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/pkg/errors"
+)
+
+// methods sells are matched against buys with
+const (
+	CostBasisFIFO    = "fifo"
+	CostBasisAverage = "average"
+)
+
+// quantityTolerance absorbs the rounding of fractional units
+const quantityTolerance = 1e-6
+
+// OpenLot is the part of a buy still held
+type OpenLot struct {
+	Account string `json:"account"`
+	// AssetClass is equity or mutual_funds, the symbol of a fund is its name
+	AssetClass string    `json:"asset_class"`
+	Symbol     string    `json:"symbol"`
+	ISIN       string    `json:"isin"`
+	BuyDate    time.Time `json:"buy_date"`
+	Quantity   float64   `json:"quantity"`
+	// Price is the cost per share, the average cost of the holding with average cost basis
+	Price float64 `json:"price"`
+	// Charges are the charges of the buy on the units held, Cost includes them
+	Charges     Charges `json:"charges"`
+	Cost        float64 `json:"cost"`
+	HoldingDays int     `json:"holding_days"`
+	// MarketValue and UnrealisedGain are set when the symbol has a price
+	MarketValue    float64    `json:"market_value"`
+	UnrealisedGain float64    `json:"unrealised_gain"`
+	Origin         *LotOrigin `json:"origin,omitempty"`
+}
+
+// LotOrigin is the scrip bought for a lot of shares moved to a successor symbol, or whose
+// cost was split by a demerger
+type LotOrigin struct {
+	Symbol string `json:"symbol"`
+	ISIN   string `json:"isin"`
+	// ChangedOn is the day the successor took over, unset when the symbol is unchanged
+	ChangedOn time.Time `json:"changed_on"`
+	// CostFactor is the price of a share of the lot per price of a share of the scrip
+	// bought, splits and bonuses aside
+	CostFactor float64 `json:"cost_factor"`
+}
+
+// ClosedLot is the part of a buy matched with a sell
+type ClosedLot struct {
+	Account    string    `json:"account"`
+	AssetClass string    `json:"asset_class"`
+	Symbol     string    `json:"symbol"`
+	ISIN       string    `json:"isin"`
+	BuyDate    time.Time `json:"buy_date"`
+	SellDate   time.Time `json:"sell_date"`
+	Quantity   float64   `json:"quantity"`
+	BuyPrice   float64   `json:"buy_price"`
+	SellPrice  float64   `json:"sell_price"`
+	// BuyCharges are included in Cost, SellCharges are deducted from Proceeds
+	BuyCharges   Charges    `json:"buy_charges"`
+	SellCharges  Charges    `json:"sell_charges"`
+	Cost         float64    `json:"cost"`
+	Proceeds     float64    `json:"proceeds"`
+	RealisedGain float64    `json:"realised_gain"`
+	HoldingDays  int        `json:"holding_days"`
+	Origin       *LotOrigin `json:"origin,omitempty"`
+}
+
+// lotTrade is a trade as matched into lots, of a share or a fund
+type lotTrade struct {
+	account    string
+	assetClass string
+	symbol     string
+	isin       string
+	date       time.Time
+	sell       bool
+	quantity   float64
+	price      float64
+	charges    Charges
+	origin     *LotOrigin
+}
+
+// lotBook holds the lots matched from the trades of a holding
+type lotBook struct {
+	open   []OpenLot
+	closed []ClosedLot
+	// unmatched is the quantity sold without a buy to match it with, e.g. a missing tradebook
+	unmatched float64
+}
+
+// matchLots matches the sells of trades with their buys, trades must be of one holding
+// of one account in date order. now sets the holding period of the open lots.
+func matchLots(trades []lotTrade, method string, now time.Time) lotBook {
+	var book lotBook
+	for _, trade := range trades {
+		if !trade.sell {
+			book.open = append(book.open, OpenLot{
+				Account:    trade.account,
+				AssetClass: trade.assetClass,
+				Symbol:     trade.symbol,
+				ISIN:       trade.isin,
+				BuyDate:    trade.date,
+				Quantity:   trade.quantity,
+				Price:      trade.price,
+				Charges:    trade.charges,
+				Origin:     trade.origin,
+			})
+			if method == CostBasisAverage {
+				averageLots(book.open)
+			}
+			continue
+		}
+
+		remaining := trade.quantity
+		for remaining > quantityTolerance && len(book.open) > 0 {
+			lot := &book.open[0]
+			quantity := math.Min(remaining, lot.Quantity)
+			buyCharges := lot.Charges.Scale(quantity / lot.Quantity)
+			sellCharges := trade.charges.Scale(quantity / trade.quantity)
+			cost := quantity*lot.Price + buyCharges.Total()
+			proceeds := quantity*trade.price - sellCharges.Total()
+			book.closed = append(book.closed, ClosedLot{
+				Account:      trade.account,
+				AssetClass:   trade.assetClass,
+				Symbol:       trade.symbol,
+				ISIN:         lot.ISIN,
+				BuyDate:      lot.BuyDate,
+				SellDate:     trade.date,
+				Quantity:     quantity,
+				BuyPrice:     lot.Price,
+				SellPrice:    trade.price,
+				BuyCharges:   buyCharges,
+				SellCharges:  sellCharges,
+				Cost:         cost,
+				Proceeds:     proceeds,
+				RealisedGain: proceeds - cost,
+				HoldingDays:  holdingDays(lot.BuyDate, trade.date),
+				Origin:       lot.Origin,
+			})
+			lot.Charges = lot.Charges.Scale(1 - quantity/lot.Quantity)
+			lot.Quantity -= quantity
+			remaining -= quantity
+			if lot.Quantity <= quantityTolerance {
+				book.open = book.open[1:]
+			}
+		}
+		if remaining > quantityTolerance {
+			book.unmatched += remaining
+		}
+	}
+	for i := range book.open {
+		book.open[i].Cost = book.open[i].Quantity*book.open[i].Price + book.open[i].Charges.Total()
+		book.open[i].HoldingDays = holdingDays(book.open[i].BuyDate, now)
+	}
+	return book
+}
+
+// averageLots prices every open lot at the average cost of the holding, lots keep
+// their buy dates for holding periods
+func averageLots(lots []OpenLot) {
+	var (
+		quantity, cost float64
+		charges        Charges
+	)
+	for _, lot := range lots {
+		quantity += lot.Quantity
+		cost += lot.Quantity * lot.Price
+		charges = charges.add(lot.Charges)
+	}
+	if quantity <= 0 {
+		return
+	}
+	for i := range lots {
+		lots[i].Price = cost / quantity
+		lots[i].Charges = charges.Scale(lots[i].Quantity / quantity)
+	}
+}
+
+func holdingDays(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// LatestPrices serves the last candle of a symbol adjusted for splits and bonuses, on
+// the scale of the adjusted lot quantities
+type LatestPrices interface {
+	GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool)
+}
+
+// SymbolPnL is the profit and loss of the holding of a symbol
+type SymbolPnL struct {
+	Symbol     ScriptName  `json:"symbol"`
+	Quantity   float64     `json:"quantity"`
+	Cost       float64     `json:"cost"`
+	Realised   float64     `json:"realised"`
+	Unrealised float64     `json:"unrealised"`
+	OpenLots   []OpenLot   `json:"open_lots"`
+	ClosedLots []ClosedLot `json:"closed_lots"`
+	// UnmatchedQuantity was sold without a buy to match
+	UnmatchedQuantity float64 `json:"unmatched_quantity"`
+	// LastPrice is the close of the latest candle, PricedAt its day, unset without price history
+	LastPrice   float64   `json:"last_price"`
+	PricedAt    time.Time `json:"priced_at"`
+	MarketValue float64   `json:"market_value"`
+}
+
+// PortfolioPnL is the profit and loss of every equity holding of an account, or the household
+type PortfolioPnL struct {
+	Account     string      `json:"account"`
+	CostBasis   string      `json:"cost_basis"`
+	Cost        float64     `json:"cost"`
+	MarketValue float64     `json:"market_value"`
+	Realised    float64     `json:"realised"`
+	Unrealised  float64     `json:"unrealised"`
+	Symbols     []SymbolPnL `json:"symbols"`
+}
+
+// SetCostBasis sets how sells are matched with buys, fifo or average
+func (t *TradebookService) SetCostBasis(method string) error {
+	switch method {
+	case "":
+		method = CostBasisFIFO
+	case CostBasisFIFO, CostBasisAverage:
+	default:
+		return errors.Errorf("unknown cost basis %q, expected fifo or average", method)
+	}
+	t.state.costBasis.Store(method)
+	return nil
+}
+
+func (t *TradebookService) costBasis() string {
+	if method, ok := t.state.costBasis.Load().(string); ok {
+		return method
+	}
+	return CostBasisFIFO
+}
+
+// equityLots matches the lots of symbol, trades are adjusted for splits and bonuses
+func (t *TradebookService) equityLots(symbol ScriptName, method string, now time.Time) lotBook {
+	var trades []lotTrade
+	for _, trade := range t.GetAdjustedEquityTrades(symbol) {
+		trades = append(trades, lotTrade{
+			account:    trade.Account,
+			assetClass: AssetEquity,
+			symbol:     trade.Symbol,
+			isin:       trade.Isin,
+			date:       trade.TradeDate,
+			sell:       trade.TradeType == "sell",
+			quantity:   trade.Quantity,
+			price:      trade.Price,
+			charges:    trade.Charges,
+			origin:     trade.origin(),
+		})
+	}
+	return matchAccountLots(trades, method, now)
+}
+
+// origin is the scrip bought for a trade the symbol lineage moved or split, nil for others
+func (e EquityTrade) origin() *LotOrigin {
+	if e.OriginalSymbol == "" && e.CostFactor == 0 {
+		return nil
+	}
+	origin := &LotOrigin{Symbol: e.OriginalSymbol, ISIN: e.OriginalISIN, ChangedOn: e.SymbolChangedOn, CostFactor: e.CostFactor}
+	if origin.Symbol == "" {
+		// the parent of a demerger keeps its symbol
+		origin.Symbol, origin.ISIN = e.Symbol, e.Isin
+	}
+	if origin.CostFactor == 0 {
+		origin.CostFactor = 1
+	}
+	return origin
+}
+
+// fundLots matches the lots of the fund isin
+func (t *TradebookService) fundLots(isin ISIN, method string, now time.Time) lotBook {
+	name := t.GetFundNameFromISIN(isin).String()
+	var trades []lotTrade
+	for _, trade := range t.GetMutualFundsTradebook().MutualFundsTradebook[isin] {
+		trades = append(trades, lotTrade{
+			account:    trade.Account,
+			assetClass: AssetMutualFunds,
+			symbol:     name,
+			isin:       trade.Isin,
+			date:       trade.TradeDate,
+			sell:       trade.TradeType == "sell",
+			quantity:   trade.Quantity,
+			price:      trade.Price,
+		})
+	}
+	return matchAccountLots(trades, method, now)
+}
+
+// matchAccountLots matches the lots of every account separately, trades are of one holding in date order
+func matchAccountLots(trades []lotTrade, method string, now time.Time) lotBook {
+	byAccount := make(map[string][]lotTrade)
+	var accounts []string
+	for _, trade := range trades {
+		if _, ok := byAccount[trade.account]; !ok {
+			accounts = append(accounts, trade.account)
+		}
+		byAccount[trade.account] = append(byAccount[trade.account], trade)
+	}
+	var book lotBook
+	for _, account := range accounts {
+		accountBook := matchLots(byAccount[account], method, now)
+		book.open = append(book.open, accountBook.open...)
+		book.closed = append(book.closed, accountBook.closed...)
+		book.unmatched += accountBook.unmatched
+	}
+	sort.SliceStable(book.open, func(i, j int) bool { return book.open[i].BuyDate.Before(book.open[j].BuyDate) })
+	sort.SliceStable(book.closed, func(i, j int) bool { return book.closed[i].SellDate.Before(book.closed[j].SellDate) })
+	return book
+}
+
+// GetLots matches the lots of every share and fund by method, for tax it must be fifo
+func (t *TradebookService) GetLots(method string) ([]OpenLot, []ClosedLot) {
+	now := time.Now()
+	var (
+		open   []OpenLot
+		closed []ClosedLot
+	)
+	for _, symbol := range t.GetEquityList() {
+		book := t.equityLots(symbol, method, now)
+		open = append(open, book.open...)
+		closed = append(closed, book.closed...)
+	}
+	for isin := range t.GetMutualFundsTradebook().MutualFundsTradebook {
+		book := t.fundLots(isin, method, now)
+		open = append(open, book.open...)
+		closed = append(closed, book.closed...)
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		if !open[i].BuyDate.Equal(open[j].BuyDate) {
+			return open[i].BuyDate.Before(open[j].BuyDate)
+		}
+		return open[i].Symbol < open[j].Symbol
+	})
+	sort.SliceStable(closed, func(i, j int) bool {
+		if !closed[i].SellDate.Equal(closed[j].SellDate) {
+			return closed[i].SellDate.Before(closed[j].SellDate)
+		}
+		return closed[i].Symbol < closed[j].Symbol
+	})
+	return open, closed
+}
+
+// GetSymbolPnL returns the lots and profit and loss of symbol, open lots are priced off
+// prices when it has a candle of symbol
+func (t *TradebookService) GetSymbolPnL(symbol string, prices LatestPrices) (SymbolPnL, error) {
+	script := t.state.lineages.Load().Current(ScriptName(strings.ToUpper(symbol)))
+	if _, ok := t.GetEquityTradebook().EquityTradebook[script]; !ok {
+		return SymbolPnL{}, errors.Errorf("no data for symbol: %s", symbol)
+	}
+	return t.symbolPnL(script, prices, time.Now()), nil
+}
+
+func (t *TradebookService) symbolPnL(symbol ScriptName, prices LatestPrices, now time.Time) SymbolPnL {
+	book := t.equityLots(symbol, t.costBasis(), now)
+	pnl := SymbolPnL{
+		Symbol:            symbol,
+		OpenLots:          book.open,
+		ClosedLots:        book.closed,
+		UnmatchedQuantity: book.unmatched,
+	}
+	if pnl.OpenLots == nil {
+		pnl.OpenLots = []OpenLot{}
+	}
+	if pnl.ClosedLots == nil {
+		pnl.ClosedLots = []ClosedLot{}
+	}
+	for _, lot := range book.closed {
+		pnl.Realised += lot.RealisedGain
+	}
+	for _, lot := range book.open {
+		pnl.Quantity += lot.Quantity
+		pnl.Cost += lot.Cost
+	}
+
+	if prices == nil {
+		return pnl
+	}
+	candle, ok := prices.GetAdjustedLatestPrice(symbol.String())
+	if !ok {
+		return pnl
+	}
+	pnl.LastPrice, pnl.PricedAt = float64(candle.Close), candle.Timestamps
+	for i := range pnl.OpenLots {
+		lot := &pnl.OpenLots[i]
+		lot.MarketValue = lot.Quantity * pnl.LastPrice
+		lot.UnrealisedGain = lot.MarketValue - lot.Cost
+		pnl.MarketValue += lot.MarketValue
+	}
+	pnl.Unrealised = pnl.MarketValue - pnl.Cost
+	return pnl
+}
+
+// GetEquityPnL returns the realised and unrealised profit and loss of every equity holding
+func (t *TradebookService) GetEquityPnL(prices LatestPrices) PortfolioPnL {
+	portfolio := PortfolioPnL{Account: t.account, CostBasis: t.costBasis(), Symbols: []SymbolPnL{}}
+	now := time.Now()
+	symbols := append([]ScriptName{}, t.GetEquityList()...)
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i] < symbols[j] })
+	for _, symbol := range symbols {
+		pnl := t.symbolPnL(symbol, prices, now)
+		portfolio.Cost += pnl.Cost
+		portfolio.MarketValue += pnl.MarketValue
+		portfolio.Realised += pnl.Realised
+		portfolio.Unrealised += pnl.Unrealised
+		portfolio.Symbols = append(portfolio.Symbols, pnl)
+	}
+	return portfolio
+}
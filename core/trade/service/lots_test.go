@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+type fixedPrices map[string]float32
+
+func (f fixedPrices) GetAdjustedLatestPrice(symbol string) (models.EquityPriceData, bool) {
+	price, ok := f[symbol]
+	return models.EquityPriceData{Timestamps: day(31), Close: price}, ok
+}
+
+func TestEquityPnL(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2023.csv",
+		"INFY,INE009A01021,2023-01-02,NSE,EQ,EQ,buy,false,10,100,1,101,\n"+
+			"INFY,INE009A01021,2023-02-01,NSE,EQ,EQ,buy,false,10,200,2,102,\n"+
+			"INFY,INE009A01021,2023-03-01,NSE,EQ,EQ,sell,false,15,300,3,103,\n"+
+			"TCS,INE467B01029,2023-03-01,NSE,EQ,EQ,buy,false,1,3000,4,104,\n")
+	tradebook := getTradebookService(t, dir, "")
+	prices := fixedPrices{"INFY": 250}
+
+	t.Run("matches sells with the earliest buys", func(t *testing.T) {
+		pnl, err := tradebook.GetSymbolPnL("infy", prices)
+		require.NoError(t, err)
+		require.Len(t, pnl.ClosedLots, 2)
+		assert.Equal(t, 10.0, pnl.ClosedLots[0].Quantity)
+		assert.Equal(t, 2000.0, pnl.ClosedLots[0].RealisedGain)
+		assert.Equal(t, 58, pnl.ClosedLots[0].HoldingDays)
+		assert.Equal(t, 5.0, pnl.ClosedLots[1].Quantity)
+		assert.Equal(t, 500.0, pnl.ClosedLots[1].RealisedGain)
+		assert.Equal(t, 2500.0, pnl.Realised)
+
+		require.Len(t, pnl.OpenLots, 1)
+		assert.Equal(t, 5.0, pnl.Quantity)
+		assert.Equal(t, 1000.0, pnl.Cost)
+		assert.Equal(t, 1250.0, pnl.MarketValue)
+		assert.Equal(t, 250.0, pnl.Unrealised)
+		assert.Equal(t, day(31), pnl.PricedAt)
+
+		breakdown, err := tradebook.GetEqBreakdown("INFY")
+		require.NoError(t, err)
+		assert.Equal(t, 1000.0, breakdown.TotalInvestment)
+		assert.Equal(t, 2500.0, breakdown.RealisedGain)
+	})
+
+	t.Run("matches sells at the average cost", func(t *testing.T) {
+		require.NoError(t, tradebook.SetCostBasis(service.CostBasisAverage))
+		defer func() { require.NoError(t, tradebook.SetCostBasis(service.CostBasisFIFO)) }()
+
+		pnl, err := tradebook.GetSymbolPnL("INFY", prices)
+		require.NoError(t, err)
+		assert.Equal(t, 15*(300.0-150), pnl.Realised)
+		assert.Equal(t, 750.0, pnl.Cost)
+		assert.Equal(t, 500.0, pnl.Unrealised)
+	})
+
+	t.Run("totals every holding", func(t *testing.T) {
+		portfolio := tradebook.GetEquityPnL(prices)
+		assert.Equal(t, service.CostBasisFIFO, portfolio.CostBasis)
+		require.Len(t, portfolio.Symbols, 2)
+		assert.Equal(t, 2500.0, portfolio.Realised)
+		assert.Equal(t, 4000.0, portfolio.Cost)
+		// TCS has no price history
+		assert.Equal(t, 1250.0, portfolio.MarketValue)
+		assert.True(t, portfolio.Symbols[1].PricedAt.IsZero())
+	})
+
+	t.Run("lists the lots of shares and funds", func(t *testing.T) {
+		mfDir := t.TempDir()
+		writeTradeFile(t, mfDir, "coin.csv",
+			"PARAG PARIKH FLEXI CAP FUND - DIRECT PLAN,INF879O01027,2023-01-02,,,,buy,false,100,50,1,101,\n"+
+				"PARAG PARIKH FLEXI CAP FUND - DIRECT PLAN,INF879O01027,2024-02-01,,,,sell,false,40,70,2,102,\n")
+		tradebook := getTradebookService(t, dir, mfDir)
+		open, closed := tradebook.GetLots(service.CostBasisFIFO)
+		require.Len(t, open, 3)
+		require.Len(t, closed, 3)
+		fund := closed[2]
+		assert.Equal(t, service.AssetMutualFunds, fund.AssetClass)
+		assert.Equal(t, "PARAG PARIKH FLEXI CAP FUND - DIRECT PLAN", fund.Symbol)
+		assert.Equal(t, 800.0, fund.RealisedGain)
+		assert.Equal(t, service.AssetEquity, closed[0].AssetClass)
+	})
+
+	assert.Error(t, tradebook.SetCostBasis("lifo"))
+	_, err := tradebook.GetSymbolPnL("WIPRO", prices)
+	assert.Error(t, err)
+}
+
+func TestEquityPnLSplitAfterLastCandle(t *testing.T) {
+	actions := service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "INFY", Type: service.ActionSplit, ExDate: day(5), Ratio: "1:5", Factor: 5},
+	})
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2024.csv", "INFY,INE009A01021,2024-01-02,NSE,EQ,EQ,buy,false,10,1500,1,101,\n")
+	tradebook := getTradebookService(t, dir, "")
+	tradebook.SetCorporateActions(actions)
+
+	// the cache has not caught up with the split yet
+	provider := &fakeProvider{candles: []models.EquityPriceData{{Timestamps: day(4), Close: 1600}}}
+	cache := service.GetEquityTrendCache(slog.Default(), nil, provider, t.TempDir(), 1)
+	cache.BuildPriceHistoryCache([]service.ScriptName{"INFY"}, nil)
+	cache.SetCorporateActions(actions)
+
+	pnl, err := tradebook.GetSymbolPnL("INFY", cache)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, pnl.Quantity)
+	assert.Equal(t, 320.0, pnl.LastPrice)
+	assert.Equal(t, 16000.0, pnl.MarketValue)
+	assert.Equal(t, 1000.0, pnl.Unrealised)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// priceHistory holds the cached price series of every symbol as an immutable
+// snapshot. Readers load the current snapshot without locking, writers publish
+// a copy with the changed series and swap it in atomically. Neither the map nor
+// the series of a published snapshot are modified afterwards.
+type priceHistory[K comparable, V any] struct {
+	current atomic.Pointer[map[K][]V]
+	// mu serialises writers
+	mu sync.Mutex
+}
+
+func newPriceHistory[K comparable, V any](initial map[K][]V) *priceHistory[K, V] {
+	if initial == nil {
+		initial = make(map[K][]V)
+	}
+	p := &priceHistory[K, V]{}
+	p.current.Store(&initial)
+	return p
+}
+
+// snapshot returns the current series of every symbol, callers must not modify it
+func (p *priceHistory[K, V]) snapshot() map[K][]V {
+	return *p.current.Load()
+}
+
+func (p *priceHistory[K, V]) get(key K) []V {
+	return p.snapshot()[key]
+}
+
+// publish swaps in a snapshot with series set for key, must be called with mu held
+func (p *priceHistory[K, V]) publish(key K, series []V) {
+	current := p.snapshot()
+	next := make(map[K][]V, len(current)+1)
+	for k, v := range current {
+		next[k] = v
+	}
+	next[key] = series
+	p.current.Store(&next)
+}
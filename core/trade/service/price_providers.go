@@ -0,0 +1,53 @@
+package service
+
+import (
+	"log/slog"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers/amfi"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers/bhavcopy"
+	MC "github.com/Mryashbhardwaj/marketAnalysis/external/trackers/moneyControl"
+	"github.com/pkg/errors"
+)
+
+// PriceProviders are the equity and mutual fund provider chains and the registry they are built from
+type PriceProviders struct {
+	Equity      trackers.PriceProvider
+	MutualFunds trackers.PriceProvider
+	Registry    *trackers.Registry
+}
+
+// GetPriceProviders builds the equity and mutual fund price provider chains
+// in the order configured for each asset class, knownFunds limits what offline
+// NAV files are loaded into memory
+func GetPriceProviders(cfg *config.Config, knownFunds map[ISIN]FundName, logger *slog.Logger) (*PriceProviders, error) {
+	client := trackers.NewHTTPClient(cfg.Refresh.RequestsPerSecond, cfg.Refresh.MaxRetries, logger)
+	registry := trackers.NewRegistry(
+		MC.NewProvider(client, logger),
+	)
+
+	if cfg.Equity.BhavcopyDirectory != "" {
+		registry.Register(bhavcopy.NewProvider(cfg.Equity.BhavcopyDirectory, logger))
+	}
+
+	if cfg.MutualFunds.AMFINAVDirectory != "" {
+		var isins []string
+		for isin := range knownFunds {
+			isins = append(isins, string(isin))
+		}
+		registry.Register(amfi.NewProvider(cfg.MutualFunds.AMFINAVDirectory, isins...))
+	}
+
+	equityProvider, err := registry.Chain(cfg.Equity.PriceProviders)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid equity price providers")
+	}
+
+	mfProvider, err := registry.Chain(cfg.MutualFunds.PriceProviders)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mutual funds price providers")
+	}
+
+	return &PriceProviders{Equity: equityProvider, MutualFunds: mfProvider, Registry: registry}, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadRecordsFile reads a YAML list of maps or a CSV with a header row into records
+// keyed by column name, every record has the columns listed in required
+func ReadRecordsFile(fileName string, required []string) ([]map[string]string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []map[string]string
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&records); err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "failed to parse %s", fileName)
+		}
+	case ".csv":
+		records, err = readRecordsCSV(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", fileName)
+		}
+	default:
+		return nil, errors.Errorf("%s is neither YAML nor CSV", fileName)
+	}
+
+	for i, record := range records {
+		for _, column := range required {
+			if strings.TrimSpace(record[column]) == "" {
+				return nil, errors.Errorf("record %d of %s has no %s", i+1, fileName, column)
+			}
+		}
+	}
+	return records, nil
+}
+
+func readRecordsCSV(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		header[i] = normaliseColumn(name)
+	}
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if isBlank(row) {
+			continue
+		}
+		record := make(map[string]string, len(header))
+		for i, value := range row {
+			if i < len(header) {
+				record[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseRatio parses a ratio written as a:b
+func parseRatio(ratio string) (float64, float64, error) {
+	left, right, ok := strings.Cut(ratio, ":")
+	if ok {
+		a, errA := strconv.ParseFloat(strings.TrimSpace(left), 64)
+		b, errB := strconv.ParseFloat(strings.TrimSpace(right), 64)
+		if errA == nil && errB == nil && a > 0 && b > 0 {
+			return a, b, nil
+		}
+	}
+	return 0, 0, errors.Errorf("invalid ratio %q, expected a:b", ratio)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/workerpool"
+	"github.com/pkg/errors"
+)
+
+type RefreshStatus string
+
+const (
+	RefreshOK      RefreshStatus = "ok"
+	RefreshFailed  RefreshStatus = "failed"
+	RefreshSkipped RefreshStatus = "skipped"
+)
+
+// errUpToDate is returned by a symbol refresh that found nothing new upstream
+var errUpToDate = errors.New("already up to date")
+
+// RefreshResult is the outcome of refreshing the price history of one symbol or ISIN
+type RefreshResult struct {
+	Symbol     string        `json:"symbol"`
+	Name       string        `json:"name,omitempty"`
+	Status     RefreshStatus `json:"status"`
+	DurationMs int64         `json:"duration_ms"`
+	RowsAdded  int           `json:"rows_added"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type RefreshReport struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Total      int             `json:"total"`
+	OK         int             `json:"ok"`
+	Failed     int             `json:"failed"`
+	Skipped    int             `json:"skipped"`
+	Results    []RefreshResult `json:"results"`
+}
+
+// Err joins the errors of every failed symbol, nil when nothing failed
+func (r RefreshReport) Err() error {
+	var errorList []string
+	for _, result := range r.Results {
+		if result.Status == RefreshFailed {
+			errorList = append(errorList, fmt.Sprintf("%s: %s", result.Symbol, result.Error))
+		}
+	}
+	if len(errorList) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errorList, "\n"))
+}
+
+// RefreshProgress is called after every symbol of a refresh, done counts the symbols finished so far
+type RefreshProgress func(done, total int, result RefreshResult)
+
+type refreshTask struct {
+	symbol string
+	name   string
+}
+
+// runRefresh refreshes every task on a bounded worker pool. refresh returns the
+// number of price points added, errUpToDate marks the symbol as skipped.
+func runRefresh(tasks []refreshTask, concurrency int, progress RefreshProgress, refresh func(symbol string) (int, error)) RefreshReport {
+	report := RefreshReport{
+		StartedAt: time.Now(),
+		Total:     len(tasks),
+		Results:   make([]RefreshResult, 0, len(tasks)),
+	}
+
+	var mu sync.Mutex
+	workerpool.Run(tasks, concurrency, func(task refreshTask) {
+		start := time.Now()
+		rowsAdded, err := refresh(task.symbol)
+		result := RefreshResult{
+			Symbol:     task.symbol,
+			Name:       task.name,
+			Status:     RefreshOK,
+			DurationMs: time.Since(start).Milliseconds(),
+			RowsAdded:  rowsAdded,
+		}
+		switch {
+		case errors.Is(err, errUpToDate):
+			result.Status = RefreshSkipped
+		case err != nil:
+			result.Status = RefreshFailed
+			result.Error = err.Error()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		report.Results = append(report.Results, result)
+		switch result.Status {
+		case RefreshOK:
+			report.OK++
+		case RefreshFailed:
+			report.Failed++
+		case RefreshSkipped:
+			report.Skipped++
+		}
+		if progress != nil {
+			progress(len(report.Results), report.Total, result)
+		}
+	})
+
+	report.FinishedAt = time.Now()
+	return report
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// kinds of symbol lineage events
+const (
+	LineageRename   = "rename"
+	LineageMerger   = "merger"
+	LineageDemerger = "demerger"
+)
+
+// SymbolLineage is a symbol, or ISIN, succeeded by another from an effective date. On a
+// rename or merger the holding moves to the successor, on a demerger the holding is kept
+// and the successor's shares are allotted on top of it. Ratio is old:new shares.
+type SymbolLineage struct {
+	Type          string     `json:"type"`
+	FromSymbol    ScriptName `json:"from_symbol"`
+	FromISIN      string     `json:"from_isin"`
+	ToSymbol      ScriptName `json:"to_symbol"`
+	ToISIN        string     `json:"to_isin"`
+	EffectiveDate time.Time  `json:"effective_date"`
+	Ratio         string     `json:"ratio"`
+	// Factor is the number of successor shares per share of the old symbol
+	Factor float64 `json:"factor"`
+	// CostShare is the part of the cost of a demerged holding carried over to the successor
+	CostShare float64 `json:"cost_share"`
+}
+
+// SymbolLineages consolidates the trades of old symbols under their successors.
+// A nil SymbolLineages leaves symbols as traded.
+type SymbolLineages struct {
+	// lineages ordered by effective date so chains resolve in order
+	lineages []SymbolLineage
+}
+
+// LoadSymbolLineages reads the renames, mergers and demergers listed in a YAML or CSV
+// file with the columns type, from_symbol, from_isin, to_symbol, to_isin, effective_date,
+// ratio and cost_share
+func LoadSymbolLineages(fileName string) (*SymbolLineages, error) {
+	records, err := ReadRecordsFile(fileName, []string{"type", "from_symbol", "to_symbol", "effective_date"})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read symbol lineage")
+	}
+	lineages := make([]SymbolLineage, 0, len(records))
+	for i, record := range records {
+		lineage, err := parseSymbolLineage(record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "symbol lineage %d of %s", i+1, fileName)
+		}
+		lineages = append(lineages, lineage)
+	}
+	return NewSymbolLineages(lineages), nil
+}
+
+func parseSymbolLineage(record map[string]string) (SymbolLineage, error) {
+	effectiveDate, err := time.Parse(time.DateOnly, strings.TrimSpace(record["effective_date"]))
+	if err != nil {
+		return SymbolLineage{}, errors.Errorf("invalid effective_date %q, expected YYYY-MM-DD", record["effective_date"])
+	}
+	lineage := SymbolLineage{
+		Type:          strings.ToLower(strings.TrimSpace(record["type"])),
+		FromSymbol:    ScriptName(strings.ToUpper(strings.TrimSpace(record["from_symbol"]))),
+		FromISIN:      strings.TrimSpace(record["from_isin"]),
+		ToSymbol:      ScriptName(strings.ToUpper(strings.TrimSpace(record["to_symbol"]))),
+		ToISIN:        strings.TrimSpace(record["to_isin"]),
+		EffectiveDate: effectiveDate,
+		Ratio:         record["ratio"],
+		Factor:        1,
+	}
+	if lineage.Ratio != "" {
+		old, new, err := parseRatio(lineage.Ratio)
+		if err != nil {
+			return SymbolLineage{}, err
+		}
+		lineage.Factor = new / old
+	}
+	switch lineage.Type {
+	case LineageRename, LineageMerger:
+	case LineageDemerger:
+		if record["cost_share"] == "" {
+			return SymbolLineage{}, errors.New("cost_share is required for a demerger")
+		}
+		lineage.CostShare, err = strconv.ParseFloat(strings.TrimSpace(record["cost_share"]), 64)
+		if err != nil || lineage.CostShare < 0 || lineage.CostShare > 1 {
+			return SymbolLineage{}, errors.Errorf("invalid cost_share %q, expected a fraction", record["cost_share"])
+		}
+	default:
+		return SymbolLineage{}, errors.Errorf("unknown lineage type %q, expected rename, merger or demerger", record["type"])
+	}
+	if lineage.FromSymbol == lineage.ToSymbol {
+		return SymbolLineage{}, errors.Errorf("%s can not succeed itself", lineage.FromSymbol)
+	}
+	return lineage, nil
+}
+
+// NewSymbolLineages orders lineages by effective date
+func NewSymbolLineages(lineages []SymbolLineage) *SymbolLineages {
+	sorted := append([]SymbolLineage{}, lineages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].EffectiveDate.Before(sorted[j].EffectiveDate)
+	})
+	return &SymbolLineages{lineages: sorted}
+}
+
+// Current returns the symbol the holding of symbol is held as today, following renames and mergers
+func (s *SymbolLineages) Current(symbol ScriptName) ScriptName {
+	if s == nil {
+		return symbol
+	}
+	for _, lineage := range s.lineages {
+		if lineage.Type != LineageDemerger && lineage.FromSymbol == symbol {
+			symbol = lineage.ToSymbol
+		}
+	}
+	return symbol
+}
+
+func (l SymbolLineage) matches(trade EquityTrade) bool {
+	if !trade.TradeDate.Before(l.EffectiveDate) {
+		return false
+	}
+	return ScriptName(trade.Symbol) == l.FromSymbol || (l.FromISIN != "" && trade.Isin == l.FromISIN)
+}
+
+// consolidate returns the tradebook with the trades of old symbols moved to, or for a
+// demerger copied to, their successors. Moved trades keep the symbol traded as OriginalSymbol
+// and the effective date of the move, so actions of the old symbol after it are not applied.
+func (s *SymbolLineages) consolidate(raw *EquityTradebook) *EquityTradebook {
+	if s == nil || len(s.lineages) == 0 {
+		return raw
+	}
+	var trades []EquityTrade
+	for _, symbol := range raw.AllShares {
+		trades = append(trades, raw.EquityTradebook[symbol]...)
+	}
+	for _, lineage := range s.lineages {
+		var allotted []EquityTrade
+		for i := range trades {
+			trade := &trades[i]
+			if !lineage.matches(*trade) {
+				continue
+			}
+			costFactor := trade.CostFactor
+			if costFactor == 0 {
+				costFactor = 1
+			}
+			successor := *trade
+			successor.Symbol = lineage.ToSymbol.String()
+			if lineage.ToISIN != "" {
+				successor.Isin = lineage.ToISIN
+			}
+			successor.Quantity *= lineage.Factor
+			successor.Price /= lineage.Factor
+			successor.CostFactor = costFactor / lineage.Factor
+			if successor.OriginalSymbol == "" {
+				successor.OriginalSymbol, successor.OriginalISIN = trade.Symbol, trade.Isin
+				successor.SymbolChangedOn = lineage.EffectiveDate
+			}
+			if lineage.Type != LineageDemerger {
+				*trade = successor
+				continue
+			}
+			// the cost of the holding is split between both companies
+			successor.Price *= lineage.CostShare
+			successor.CostFactor *= lineage.CostShare
+			trade.Price *= 1 - lineage.CostShare
+			trade.CostFactor = costFactor * (1 - lineage.CostShare)
+			allotted = append(allotted, successor)
+		}
+		trades = append(trades, allotted...)
+	}
+
+	tradebook := &EquityTradebook{EquityTradebook: make(map[ScriptName][]EquityTrade)}
+	for _, trade := range trades {
+		symbol := ScriptName(trade.Symbol)
+		if _, ok := tradebook.EquityTradebook[symbol]; !ok {
+			tradebook.AllShares = append(tradebook.AllShares, symbol)
+		}
+		tradebook.EquityTradebook[symbol] = append(tradebook.EquityTradebook[symbol], trade)
+	}
+	for _, trades := range tradebook.EquityTradebook {
+		sortEquityTrades(trades)
+	}
+	return tradebook
+}
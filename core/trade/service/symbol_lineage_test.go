@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"log/slog"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+)
+
+func TestSymbolLineages(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "lineage.yaml", `
+- type: merger
+  from_symbol: MINDTREE
+  to_symbol: LTIM
+  effective_date: 2022-11-24
+  ratio: "100:73"
+- type: rename
+  from_symbol: LTI
+  to_symbol: LTIM
+  effective_date: 2022-11-20
+- type: demerger
+  from_symbol: ITC
+  to_symbol: ITCHOTELS
+  effective_date: 2025-01-06
+  ratio: "10:1"
+  cost_share: 0.04
+`)
+	lineages, err := service.LoadSymbolLineages(path.Join(dir, "lineage.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, service.ScriptName("LTIM"), lineages.Current("MINDTREE"))
+	assert.Equal(t, service.ScriptName("ITC"), lineages.Current("ITC"))
+
+	tradebookDir := t.TempDir()
+	writeTradeFile(t, tradebookDir, "trades.csv",
+		"MINDTREE,INE018I01017,2022-01-03,NSE,EQ,EQ,buy,false,100,4000,1,101,2022-01-03T09:20:11\n"+
+			"LTI,INE214T01019,2022-02-01,NSE,EQ,EQ,buy,false,10,6000,2,102,2022-02-01T09:20:11\n"+
+			"LTIM,INE214T01019,2023-01-02,NSE,EQ,EQ,sell,false,20,5000,3,103,2023-01-02T09:20:11\n"+
+			"ITC,INE154A01025,2024-01-02,NSE,EQ,EQ,buy,false,100,450,4,104,2024-01-02T09:20:11\n")
+	tradebook := getTradebookService(t, tradebookDir, "")
+	tradebook.SetSymbolLineages(lineages)
+
+	assert.ElementsMatch(t, []service.ScriptName{"LTIM", "ITC", "ITCHOTELS"}, tradebook.GetEquityList())
+	trades := tradebook.GetEquityTradebook().EquityTradebook["LTIM"]
+	require.Len(t, trades, 3)
+	assert.Equal(t, "MINDTREE", trades[0].OriginalSymbol)
+	assert.InDelta(t, 73, trades[0].Quantity, 1e-9)
+	assert.InDelta(t, 400000, trades[0].Quantity*trades[0].Price, 1e-6)
+	assert.Equal(t, "LTI", trades[1].OriginalSymbol)
+	assert.Empty(t, trades[2].OriginalSymbol)
+
+	// breakdowns of an old symbol are of its successor
+	breakdown, err := tradebook.GetEqBreakdown("mindtree")
+	require.NoError(t, err)
+	assert.InDelta(t, 63, breakdown.NetQuantity, 1e-9)
+
+	// a demerger keeps the holding and allots the successor's shares with part of the cost
+	itc := tradebook.GetEquityTradebook().EquityTradebook["ITC"]
+	hotels := tradebook.GetEquityTradebook().EquityTradebook["ITCHOTELS"]
+	require.Len(t, itc, 1)
+	require.Len(t, hotels, 1)
+	assert.InDelta(t, 100, itc[0].Quantity, 1e-9)
+	assert.InDelta(t, 45000*0.96, itc[0].Quantity*itc[0].Price, 1e-6)
+	assert.InDelta(t, 10, hotels[0].Quantity, 1e-9)
+	assert.InDelta(t, 45000*0.04, hotels[0].Quantity*hotels[0].Price, 1e-6)
+	assert.Equal(t, "ITC", hotels[0].OriginalSymbol)
+
+	// lots keep the scrip bought and how its price carries over, to value it on an earlier day
+	open, _ := tradebook.GetLots(service.CostBasisFIFO)
+	origins := make(map[string]*service.LotOrigin)
+	for _, lot := range open {
+		origins[lot.Symbol] = lot.Origin
+	}
+	require.NotNil(t, origins["LTIM"])
+	assert.Equal(t, service.LotOrigin{Symbol: "LTI", ISIN: "INE214T01019", ChangedOn: time.Date(2022, 11, 20, 0, 0, 0, 0, time.UTC), CostFactor: 1}, *origins["LTIM"])
+	assert.Equal(t, "INE154A01025", origins["ITCHOTELS"].ISIN)
+	assert.InDelta(t, 0.4, origins["ITCHOTELS"].CostFactor, 1e-9)
+	assert.Equal(t, "ITC", origins["ITC"].Symbol)
+	assert.InDelta(t, 0.96, origins["ITC"].CostFactor, 1e-9)
+
+	// a split of the parent after the demerger is not applied to the successor's shares
+	tradebook.SetCorporateActions(service.NewCorporateActions([]service.CorporateAction{
+		{Symbol: "ITC", Type: service.ActionSplit, ExDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), Ratio: "1:2", Factor: 2},
+	}))
+	itcBreakdown, err := tradebook.GetEqBreakdown("ITC")
+	require.NoError(t, err)
+	assert.InDelta(t, 200, itcBreakdown.NetQuantity, 1e-9)
+	hotelsBreakdown, err := tradebook.GetEqBreakdown("ITCHOTELS")
+	require.NoError(t, err)
+	assert.InDelta(t, 10, hotelsBreakdown.NetQuantity, 1e-9)
+	assert.InDelta(t, 45000*0.04, hotelsBreakdown.TotalBuyValue, 1e-6)
+	tradebook.SetCorporateActions(nil)
+
+	provider := &fakeProvider{candles: []models.EquityPriceData{
+		{Timestamps: day(1), Close: 5000},
+		{Timestamps: day(2), Close: 5100},
+	}}
+	cache := service.GetEquityTrendCache(slog.Default(), nil, provider, t.TempDir(), 1)
+	cache.SetSymbolLineages(lineages)
+	report := cache.BuildPriceHistoryCache([]service.ScriptName{"MINDTREE", "LTIM"}, nil)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, "LTIM", report.Results[0].Symbol)
+	assert.Len(t, cache.GetPriceTrendInTimeRange("MINDTREE", day(1), day(2)), 1)
+
+	writeTradeFile(t, dir, "bad.csv", "type,from_symbol,to_symbol,effective_date\ndemerger,ITC,ITCHOTELS,2025-01-06\n")
+	_, err = service.LoadSymbolLineages(path.Join(dir, "bad.csv"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/pkg/errors"
+)
+
+// AllAccounts views the trades of every account together, as a household
+const AllAccounts = "all"
+
+// AccountSummary is an account the tradebook is read for
+type AccountSummary struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+}
+
+// accountTradebook holds the tradebooks of an account, or of the household
+type accountTradebook struct {
+	// rawEquity holds the trades as traded, equity has them consolidated under current symbols
+	rawEquity            *EquityTradebook
+	equity               *EquityTradebook
+	mutualFunds          *MutualFundsTradebook
+	equityIngestion      *SegmentIngestion
+	mutualFundsIngestion *SegmentIngestion
+	dividends            []Dividend
+	dividendFiles        []TradeFile
+}
+
+// tradebookSnapshot is the tradebooks of every account as loaded at once,
+// keyed by account name with the household under AllAccounts
+type tradebookSnapshot struct {
+	loadedAt time.Time
+	accounts map[string]*accountTradebook
+}
+
+func validateAccounts(accounts []config.AccountConfig) error {
+	if len(accounts) == 0 {
+		return errors.New("no accounts configured")
+	}
+	seen := make(map[string]struct{}, len(accounts))
+	for _, account := range accounts {
+		if account.Name == "" {
+			return errors.New("account name is required")
+		}
+		if account.Name == AllAccounts {
+			return errors.Errorf("account name %q is reserved for all accounts", AllAccounts)
+		}
+		if _, ok := seen[account.Name]; ok {
+			return errors.Errorf("account %q is configured twice", account.Name)
+		}
+		seen[account.Name] = struct{}{}
+		if account.EquityTradeFilesDirectory == "" && account.MutualFundsTradeFilesDirectory == "" {
+			return errors.Errorf("no tradefiles directory set for equity or mutual funds of account %q", account.Name)
+		}
+	}
+	return nil
+}
+
+// buildSnapshot reads the tradebooks of every account and merges them for the household
+func buildSnapshot(accounts []config.AccountConfig, lineages *SymbolLineages) (*tradebookSnapshot, error) {
+	snapshot := &tradebookSnapshot{
+		loadedAt: time.Now(),
+		accounts: make(map[string]*accountTradebook, len(accounts)+1),
+	}
+	books := make([]*accountTradebook, 0, len(accounts))
+	for _, account := range accounts {
+		accountBooks, err := buildAccountTradebook(account)
+		if err != nil {
+			return nil, errors.Wrapf(err, "account %s", account.Name)
+		}
+		snapshot.accounts[account.Name] = accountBooks
+		books = append(books, accountBooks)
+	}
+	snapshot.accounts[AllAccounts] = householdTradebook(books)
+	return snapshot.consolidated(lineages), nil
+}
+
+// consolidated returns a copy of the snapshot with the equity trades of every account
+// consolidated by lineages
+func (s *tradebookSnapshot) consolidated(lineages *SymbolLineages) *tradebookSnapshot {
+	next := &tradebookSnapshot{
+		loadedAt: s.loadedAt,
+		accounts: make(map[string]*accountTradebook, len(s.accounts)),
+	}
+	for name, books := range s.accounts {
+		consolidated := *books
+		consolidated.equity = lineages.consolidate(books.rawEquity)
+		next.accounts[name] = &consolidated
+	}
+	return next
+}
+
+func buildAccountTradebook(account config.AccountConfig) (*accountTradebook, error) {
+	books := &accountTradebook{
+		rawEquity:            &EquityTradebook{EquityTradebook: make(map[ScriptName][]EquityTrade)},
+		mutualFunds:          &MutualFundsTradebook{AllFunds: make(map[FundName]ISIN), ISINToFundName: make(map[ISIN]FundName), MutualFundsTradebook: make(map[ISIN][]MutualFundsTrade)},
+		equityIngestion:      &SegmentIngestion{},
+		mutualFundsIngestion: &SegmentIngestion{},
+	}
+	if account.MutualFundsTradeFilesDirectory != "" {
+		var err error
+		books.mutualFunds, books.mutualFundsIngestion, err = buildMFTradebook(account.MutualFundsTradeFilesDirectory, account.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get mutual funds tradebook")
+		}
+	}
+	if account.DividendsDirectory != "" {
+		var err error
+		books.dividends, books.dividendFiles, err = readDividends(account.DividendsDirectory, account.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read dividends")
+		}
+	}
+	if account.EquityTradeFilesDirectory != "" {
+		var err error
+		books.rawEquity, books.equityIngestion, err = buildEquityTradebook(account.EquityTradeFilesDirectory, account.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build equity tradebook")
+		}
+	}
+	return books, nil
+}
+
+// householdTradebook merges the tradebooks of every account, trades keep the account they were read for
+func householdTradebook(books []*accountTradebook) *accountTradebook {
+	if len(books) == 1 {
+		return books[0]
+	}
+
+	equity := &EquityTradebook{EquityTradebook: make(map[ScriptName][]EquityTrade)}
+	mutualFunds := &MutualFundsTradebook{
+		AllFunds:             make(map[FundName]ISIN),
+		ISINToFundName:       make(map[ISIN]FundName),
+		MutualFundsTradebook: make(map[ISIN][]MutualFundsTrade),
+	}
+	equityIngestion, mutualFundsIngestion := &SegmentIngestion{}, &SegmentIngestion{}
+	var (
+		dividends     []Dividend
+		dividendFiles []TradeFile
+	)
+	for _, account := range books {
+		for _, symbol := range account.rawEquity.AllShares {
+			if _, ok := equity.EquityTradebook[symbol]; !ok {
+				equity.AllShares = append(equity.AllShares, symbol)
+			}
+			equity.EquityTradebook[symbol] = append(equity.EquityTradebook[symbol], account.rawEquity.EquityTradebook[symbol]...)
+		}
+		for isin, trades := range account.mutualFunds.MutualFundsTradebook {
+			mutualFunds.MutualFundsTradebook[isin] = append(mutualFunds.MutualFundsTradebook[isin], trades...)
+		}
+		for isin, name := range account.mutualFunds.ISINToFundName {
+			// the first account holding a fund names it
+			if _, ok := mutualFunds.ISINToFundName[isin]; !ok {
+				mutualFunds.ISINToFundName[isin] = name
+				mutualFunds.AllFunds[name] = isin
+			}
+		}
+		dividends = append(dividends, account.dividends...)
+		dividendFiles = append(dividendFiles, account.dividendFiles...)
+		mergeIngestion(equityIngestion, account.equityIngestion)
+		mergeIngestion(mutualFundsIngestion, account.mutualFundsIngestion)
+	}
+
+	for _, trades := range equity.EquityTradebook {
+		sortEquityTrades(trades)
+	}
+	for isin := range mutualFunds.MutualFundsTradebook {
+		trades := mutualFunds.MutualFundsTradebook[isin]
+		sort.SliceStable(trades, func(i, j int) bool {
+			return trades[i].TradeDate.Before(trades[j].TradeDate)
+		})
+	}
+	sortDividends(dividends)
+	equityIngestion.Gaps = filelessMonths(equityIngestion.From, equityIngestion.To, equityIngestion.Files)
+	mutualFundsIngestion.Gaps = filelessMonths(mutualFundsIngestion.From, mutualFundsIngestion.To, mutualFundsIngestion.Files)
+
+	return &accountTradebook{
+		rawEquity:            equity,
+		mutualFunds:          mutualFunds,
+		equityIngestion:      equityIngestion,
+		mutualFundsIngestion: mutualFundsIngestion,
+		dividends:            dividends,
+		dividendFiles:        dividendFiles,
+	}
+}
+
+// mergeIngestion adds the files and trades of other to ingestion, gaps are left to the caller
+func mergeIngestion(ingestion, other *SegmentIngestion) {
+	ingestion.Files = append(ingestion.Files, other.Files...)
+	ingestion.Trades += other.Trades
+	ingestion.Duplicates = append(ingestion.Duplicates, other.Duplicates...)
+	if other.From.IsZero() {
+		return
+	}
+	if ingestion.From.IsZero() || other.From.Before(ingestion.From) {
+		ingestion.From = other.From
+	}
+	if other.To.After(ingestion.To) {
+		ingestion.To = other.To
+	}
+}
+
+// Account returns a view of the tradebook of the named account, an empty name
+// or AllAccounts views the household
+func (t *TradebookService) Account(name string) (*TradebookService, error) {
+	if name == "" {
+		name = AllAccounts
+	}
+	if _, ok := t.state.snapshot.Load().accounts[name]; !ok {
+		return nil, errors.Errorf("unknown account %q", name)
+	}
+	return &TradebookService{logger: t.logger, account: name, state: t.state}, nil
+}
+
+// SetSymbolLineages sets the renames, mergers and demergers equity trades are consolidated by
+func (t *TradebookService) SetSymbolLineages(lineages *SymbolLineages) {
+	t.state.reloadMu.Lock()
+	defer t.state.reloadMu.Unlock()
+	t.state.lineages.Store(lineages)
+	t.state.snapshot.Store(t.state.snapshot.Load().consolidated(lineages))
+}
+
+// GetAccounts lists the accounts the tradebook is read for
+func (t *TradebookService) GetAccounts() []AccountSummary {
+	accounts := make([]AccountSummary, 0, len(t.state.accounts))
+	for _, account := range t.state.accounts {
+		accounts = append(accounts, AccountSummary{Name: account.Name, Owner: account.Owner})
+	}
+	return accounts
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// columns only found in CAS statements
+const (
+	colFolio       = "folio"
+	colDescription = "description"
+	colAmount      = "amount"
+)
+
+// casFormat is the CSV form of a CAMS/KFintech consolidated account statement
+// as exported by CAS parsers, one row per transaction of a folio
+var casFormat = tradebookFormat{
+	broker:     BrokerCAS,
+	assetClass: AssetMutualFunds,
+	detect:     []string{colFolio, "scheme", "units", "nav"},
+	aliases: map[string]string{
+		"scheme": colSymbol,
+		"date":   colTradeDate,
+		"units":  colQuantity,
+		"nav":    colPrice,
+	},
+	required: []string{colFolio, colSymbol, colISIN, colTradeDate, colQuantity, colPrice},
+	prepare:  prepareCASTransaction,
+}
+
+// casTextColumns are the columns of the rows read from the text form of a CAS
+var casTextColumns = map[string]int{
+	colFolio: 0, colSymbol: 1, colISIN: 2, colTradeDate: 3, colDescription: 4, colAmount: 5, colQuantity: 6, colPrice: 7,
+}
+
+var (
+	casFolioLine  = regexp.MustCompile(`(?i)folio\s*no\s*[:.]?\s*([0-9A-Z]+(?:\s*/\s*[0-9A-Z]+)?)`)
+	casSchemeLine = regexp.MustCompile(`^\s*(.*?)\s*-?\s*ISIN\s*:\s*([A-Z]{2}[A-Z0-9]{10})`)
+	// date, description, amount, units, NAV and unit balance, negative values are in parentheses
+	casTransactionLine = regexp.MustCompile(`^\s*(\d{2}-[A-Za-z]{3}-\d{4})\s+(.+?)\s+(\(?-?[\d,]+\.\d+\)?)\s+(\(?-?[\d,]+\.\d+\)?)\s+([\d,]+\.\d+)\s+(\(?-?[\d,]+\.\d+\)?)\s*$`)
+)
+
+// readCASText reads the transactions of the text form of a CAS, as extracted from
+// the statement PDF. Lines other than folio, scheme and transaction lines are skipped.
+func readCASText(file *TradeFile, fn func(row tradebookRow) error) {
+	f, err := os.Open(file.Name)
+	if err != nil {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: err.Error()})
+		return
+	}
+	defer f.Close()
+
+	file.Broker = BrokerCAS
+	var (
+		folio, scheme, isin string
+		found               bool
+	)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := casFolioLine.FindStringSubmatch(text); match != nil {
+			folio = strings.Join(strings.Fields(match[1]), "")
+			found = true
+			continue
+		}
+		if match := casSchemeLine.FindStringSubmatch(text); match != nil {
+			scheme, isin = strings.TrimRight(match[1], " -"), match[2]
+			file.HeaderRows++
+			found = true
+			continue
+		}
+		match := casTransactionLine.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		found = true
+		row := tradebookRow{
+			file:    file.Name,
+			line:    line,
+			broker:  BrokerCAS,
+			columns: casTextColumns,
+			record:  []string{folio, scheme, isin, match[1], match[2], match[3], match[4], match[5]},
+		}
+		if isin == "" {
+			file.Errors = append(file.Errors, row.errorf("", "transaction before any scheme"))
+			continue
+		}
+		file.readRow(&casFormat, row, fn)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: err.Error()})
+	}
+	if !found {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: "no CAS folio or transaction found"})
+	}
+}
+
+// prepareCASTransaction turns a CAS transaction into a trade, the units are negative
+// for redemptions and switch outs. Transactions without units, like stamp duty and
+// dividend payouts, are ignored.
+func prepareCASTransaction(row *tradebookRow) error {
+	if row.get(colQuantity) == "" {
+		return errIgnoredRow
+	}
+	units, err := parseCASNumber(row.get(colQuantity))
+	if err != nil {
+		return row.errorf(colQuantity, "invalid number %q", row.get(colQuantity))
+	}
+	transaction := casTransaction(row.get("type") + " " + row.get(colDescription))
+	if units == 0 || transaction == "" {
+		return errIgnoredRow
+	}
+
+	// the same date is written differently by the text and CSV forms
+	tradeDate, err := row.day(colTradeDate)
+	if err != nil {
+		return err
+	}
+	row.set(colTradeDate, tradeDate.Format(time.DateOnly))
+
+	tradeType := "buy"
+	if units < 0 {
+		tradeType = "sell"
+	}
+	row.set(colTradeType, tradeType)
+	row.set(colQuantity, strconv.FormatFloat(math.Abs(units), 'f', -1, 64))
+	row.set(casTransactionColumn, transaction)
+	if amount, err := parseCASNumber(row.get(colAmount)); err == nil {
+		row.set(colAmount, strconv.FormatFloat(math.Abs(amount), 'f', -1, 64))
+	}
+	if nav, err := parseCASNumber(row.get(colPrice)); err == nil {
+		row.set(colPrice, strconv.FormatFloat(nav, 'f', -1, 64))
+	}
+
+	// statements have no transaction ids, the same transaction in overlapping
+	// statements hashes to the same id
+	row.set(colTradeID, row.syntheticTradeID(colFolio, colISIN, colTradeDate, colTradeType, colQuantity, colAmount, colPrice))
+	return nil
+}
+
+// casTransactionColumn holds the kind of a CAS transaction
+const casTransactionColumn = "transaction"
+
+// casTransaction returns the kind of transaction described, empty for transactions
+// that do not move units
+func casTransaction(description string) string {
+	text := strings.ToLower(description)
+	has := func(words ...string) bool {
+		for _, word := range words {
+			if strings.Contains(text, word) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("stamp duty", "stamp_duty", "stt", "tds", "_tax"):
+		return ""
+	case has("switch") && has("out"):
+		return "switch_out"
+	case has("switch"):
+		return "switch_in"
+	case has("reinvest"):
+		return "dividend_reinvest"
+	case has("dividend", "idcw"):
+		// payouts are paid in cash
+		return ""
+	case has("redemption", "redeem", "repurchase"):
+		return "redemption"
+	case has("sip", "systematic"):
+		return "sip"
+	default:
+		return "purchase"
+	}
+}
+
+// parseCASNumber parses an amount of a statement, negative amounts are in parentheses
+func parseCASNumber(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	if negative {
+		value = value[1 : len(value)-1]
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		number = -number
+	}
+	return number, nil
+}
+
+// casMatchWindow is how far apart the dates of the same trade may be in a CAS and a
+// broker tradebook, brokers report the order date and statements the allotment date
+const casMatchWindow = 3 * 24 * time.Hour
+
+// casDuplicates returns the indexes of the CAS trades that are also in a broker
+// tradebook, matched on ISIN, trade type, units and a trade date within casMatchWindow
+func casDuplicates(trades []MutualFundsTrade) map[int]int {
+	brokerTrades := make(map[string][]int)
+	for i, trade := range trades {
+		if trade.Broker != BrokerCAS {
+			brokerTrades[trade.Isin] = append(brokerTrades[trade.Isin], i)
+		}
+	}
+
+	duplicates := make(map[int]int)
+	matched := make(map[int]struct{})
+	for i, trade := range trades {
+		if trade.Broker != BrokerCAS {
+			continue
+		}
+		for _, j := range brokerTrades[trade.Isin] {
+			if _, ok := matched[j]; ok {
+				continue
+			}
+			other := trades[j]
+			gap := trade.TradeDate.Sub(other.TradeDate)
+			if other.TradeType == trade.TradeType && math.Abs(other.Quantity-trade.Quantity) < 0.0015 &&
+				gap <= casMatchWindow && gap >= -casMatchWindow {
+				matched[j] = struct{}{}
+				duplicates[i] = j
+				break
+			}
+		}
+	}
+	return duplicates
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tradebook columns as named in the header of Zerodha tradebook exports
+const (
+	colSymbol             = "symbol"
+	colISIN               = "isin"
+	colTradeDate          = "trade_date"
+	colExchange           = "exchange"
+	colSegment            = "segment"
+	colSeries             = "series"
+	colTradeType          = "trade_type"
+	colAuction            = "auction"
+	colQuantity           = "quantity"
+	colPrice              = "price"
+	colTradeID            = "trade_id"
+	colOrderID            = "order_id"
+	colOrderExecutionTime = "order_execution_time"
+)
+
+// zerodhaColumns is the column order assumed for tradebook files without a header
+var zerodhaColumns = []string{
+	colSymbol, colISIN, colTradeDate, colExchange, colSegment, colSeries, colTradeType,
+	colAuction, colQuantity, colPrice, colTradeID, colOrderID, colOrderExecutionTime,
+}
+
+var tradeDateLayouts = []string{
+	time.DateOnly, time.DateTime, "2006-01-02T15:04:05",
+	"02-01-2006", "02/01/2006", "02-01-2006 15:04:05", "02-01-2006 15:04", "02-01-2006 03:04 PM",
+	"02 Jan 2006", "02-Jan-2006",
+}
+
+// tradeTypes maps the trade types used by the exports to buy and sell
+var tradeTypes = map[string]string{
+	"buy":        "buy",
+	"b":          "buy",
+	"purchase":   "buy",
+	"sip":        "buy",
+	"sell":       "sell",
+	"s":          "sell",
+	"redeem":     "sell",
+	"redemption": "sell",
+}
+
+// RowError is a tradebook row that could not be loaded, Line is 0 for errors
+// about the file as a whole
+type RowError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	location := fmt.Sprintf("%s:%d", e.File, e.Line)
+	if e.Column != "" {
+		location = fmt.Sprintf("%s (%s)", location, e.Column)
+	}
+	return fmt.Sprintf("%s: %s", location, e.Message)
+}
+
+// tradebookRow is a data row of a tradebook file with its columns mapped by header name
+type tradebookRow struct {
+	file    string
+	line    int
+	broker  string
+	columns map[string]int
+	record  []string
+	// values are columns set by the format, they take precedence over the record
+	values map[string]string
+	// occurrences are shared by the rows of a file, see syntheticTradeID
+	occurrences map[string]int
+}
+
+func (r tradebookRow) errorf(column, format string, args ...interface{}) RowError {
+	return RowError{File: r.file, Line: r.line, Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+// get returns the trimmed value of column, empty when the file has no such column
+func (r tradebookRow) get(column string) string {
+	if value, ok := r.values[column]; ok {
+		return value
+	}
+	index, ok := r.columns[column]
+	if !ok || index >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[index])
+}
+
+func (r *tradebookRow) set(column, value string) {
+	if r.values == nil {
+		r.values = make(map[string]string)
+	}
+	r.values[column] = value
+}
+
+func (r tradebookRow) required(column string) (string, error) {
+	value := r.get(column)
+	if value == "" {
+		return "", r.errorf(column, "value is missing")
+	}
+	return value, nil
+}
+
+func (r tradebookRow) date(column string) (time.Time, error) {
+	value, err := r.required(column)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range tradeDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, r.errorf(column, "invalid date %q", value)
+}
+
+// day is date without the time of day
+func (r tradebookRow) day(column string) (time.Time, error) {
+	t, err := r.date(column)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// tradeType returns the trade type as buy or sell
+func (r tradebookRow) tradeType() (string, error) {
+	value, err := r.required(colTradeType)
+	if err != nil {
+		return "", err
+	}
+	tradeType, ok := tradeTypes[strings.ToLower(value)]
+	if !ok {
+		return "", r.errorf(colTradeType, "unknown trade type %q", value)
+	}
+	return tradeType, nil
+}
+
+func (r tradebookRow) float(column string) (float64, error) {
+	value, err := r.required(column)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		return 0, r.errorf(column, "invalid number %q", value)
+	}
+	return f, nil
+}
+
+func normaliseColumn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// readTradebookFile calls fn with every data row of a tradebook file of assetClass.
+// The broker format and columns are taken from the header, which may be preceded
+// by preamble rows and repeated when exports are concatenated. Rows fn rejects are
+// recorded on file and skipped. Text files are read as CAS statements, dividend
+// files are matched against dividendFormats instead of tradebookFormats.
+func readTradebookFile(file *TradeFile, assetClass string, fn func(row tradebookRow) error) {
+	if strings.EqualFold(path.Ext(file.Name), ".txt") {
+		if assetClass != AssetMutualFunds {
+			file.Errors = append(file.Errors, RowError{File: file.Name, Message: "a CAS statement only holds mutual fund trades"})
+			return
+		}
+		readCASText(file, fn)
+		return
+	}
+
+	f, err := os.Open(file.Name)
+	if err != nil {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: err.Error()})
+		return
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+
+	formats := tradebookFormats
+	if assetClass == AssetDividends {
+		formats = dividendFormats
+	}
+
+	var (
+		format  *tradebookFormat
+		columns map[string]int
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			line := 0
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			file.Errors = append(file.Errors, RowError{File: file.Name, Line: line, Message: err.Error()})
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+
+		if headerFormat, header, ok := detectFormat(formats, record); ok {
+			file.HeaderRows++
+			format, columns = headerFormat, header
+			file.Broker = format.broker
+			if format.assetClass != "" && format.assetClass != assetClass {
+				file.Errors = append(file.Errors, RowError{File: file.Name, Line: line,
+					Message: fmt.Sprintf("a %s %s export can not be read as %s trades", format.broker, format.assetClass, assetClass)})
+				columns = nil
+				continue
+			}
+			if missing := format.missingColumns(header); len(missing) > 0 {
+				file.Errors = append(file.Errors, RowError{File: file.Name, Line: line,
+					Message: "missing required columns " + strings.Join(missing, ", ")})
+				columns = nil
+			}
+			continue
+		}
+		if columns == nil {
+			if file.HeaderRows > 0 || assetClass == AssetDividends || len(record) != len(zerodhaColumns) {
+				// rows following a rejected header, or preamble before the header
+				file.IgnoredRows++
+				continue
+			}
+			format, columns = zerodhaFormat, make(map[string]int, len(zerodhaColumns))
+			for i, column := range zerodhaColumns {
+				columns[column] = i
+			}
+			file.Broker = format.broker
+		}
+
+		file.readRow(format, tradebookRow{file: file.Name, line: line, broker: format.broker, columns: columns, record: record}, fn)
+	}
+	if file.IgnoredRows > 0 && file.HeaderRows == 0 && columns == nil {
+		file.Errors = append(file.Errors, RowError{File: file.Name, Message: fmt.Sprintf("no %s header found", headerKind(assetClass))})
+	}
+}
+
+func headerKind(assetClass string) string {
+	if assetClass == AssetDividends {
+		return "dividend statement"
+	}
+	return "tradebook"
+}
+
+// readRow prepares row for its format and hands it to fn, the outcome is recorded on the file
+func (f *TradeFile) readRow(format *tradebookFormat, row tradebookRow, fn func(row tradebookRow) error) {
+	if f.occurrences == nil {
+		f.occurrences = make(map[string]int)
+	}
+	row.occurrences = f.occurrences
+
+	var err error
+	if format.prepare != nil {
+		err = format.prepare(&row)
+	}
+	if err == nil {
+		err = fn(row)
+	}
+	switch {
+	case err == nil:
+		f.Rows++
+	case errors.Is(err, errDuplicateTrade):
+		f.Duplicates++
+	case errors.Is(err, errIgnoredRow):
+		f.IgnoredRows++
+	default:
+		var rowErr RowError
+		if !errors.As(err, &rowErr) {
+			rowErr = RowError{File: f.Name, Line: row.line, Message: err.Error()}
+		}
+		f.Errors = append(f.Errors, rowErr)
+	}
+}
+
+func isBlank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// brokers the tradebook can be exported from
+const (
+	BrokerZerodha = "zerodha"
+	BrokerGroww   = "groww"
+	BrokerUpstox  = "upstox"
+	// BrokerCAS tags mutual fund trades read from a CAMS/KFintech consolidated account statement
+	BrokerCAS = "cas"
+)
+
+// asset classes a tradebook file can hold
+const (
+	AssetEquity      = "equity"
+	AssetMutualFunds = "mutual_funds"
+	// AssetDividends are the files of the dividends directory, read with dividendFormats
+	AssetDividends = "dividends"
+)
+
+// errIgnoredRow is returned for a row that holds no trade, e.g. a cancelled order
+var errIgnoredRow = errors.New("row holds no trade")
+
+// tradebookFormat is the layout of a broker export. Its columns are renamed to the
+// tradebook columns so every format is read into the same trades.
+type tradebookFormat struct {
+	broker string
+	// assetClass the export holds, empty when it can hold either
+	assetClass string
+	// detect are header names, as normalised, only found in exports of this format
+	detect []string
+	// aliases maps header names of the export to tradebook columns
+	aliases  map[string]string
+	required []string
+	// prepare fills in the columns the export does not have directly
+	prepare func(row *tradebookRow) error
+}
+
+// tradebookFormats are tried in order against every header row
+var tradebookFormats = []tradebookFormat{
+	{
+		// Zerodha Console tradebook
+		broker: BrokerZerodha,
+		detect: []string{colSymbol, colTradeID},
+		aliases: map[string]string{
+			"tradingsymbol":  colSymbol,
+			"scrip":          colSymbol,
+			"date":           colTradeDate,
+			"type":           colTradeType,
+			"qty":            colQuantity,
+			"trade_price":    colPrice,
+			"execution_time": colOrderExecutionTime,
+		},
+		required: []string{colSymbol, colISIN, colTradeDate, colTradeType, colQuantity, colPrice, colTradeID},
+	},
+	{
+		// Groww stocks order history, one row per order with its total value
+		broker:     BrokerGroww,
+		assetClass: AssetEquity,
+		detect:     []string{"stock_name", "exchange_order_id"},
+		aliases: map[string]string{
+			"type":                    colTradeType,
+			"exchange_order_id":       colOrderID,
+			"execution_date_and_time": colOrderExecutionTime,
+		},
+		required: []string{colSymbol, colOrderExecutionTime, colTradeType, colQuantity, "value", colOrderID},
+		prepare:  prepareGrowwOrder,
+	},
+	{
+		// Groww mutual funds order history
+		broker:     BrokerGroww,
+		assetClass: AssetMutualFunds,
+		detect:     []string{"scheme_name", "units", "nav"},
+		aliases: map[string]string{
+			"scheme_name":      colSymbol,
+			"transaction_type": colTradeType,
+			"units":            colQuantity,
+			"nav":              colPrice,
+			"date":             colTradeDate,
+		},
+		required: []string{colSymbol, colISIN, colTradeDate, colTradeType, colQuantity, colPrice},
+		prepare:  prepareGrowwFundOrder,
+	},
+	{
+		// Upstox trade report
+		broker:     BrokerUpstox,
+		assetClass: AssetEquity,
+		detect:     []string{"scrip_code", "trade_num"},
+		aliases: map[string]string{
+			"scrip_code": colSymbol,
+			"date":       colTradeDate,
+			"side":       colTradeType,
+			"trade_num":  colTradeID,
+			"trade_time": colOrderExecutionTime,
+		},
+		required: []string{colSymbol, colTradeDate, colTradeType, colQuantity, colPrice, colTradeID},
+	},
+	casFormat,
+}
+
+// zerodhaFormat is assumed for tradebook files without a header
+var zerodhaFormat = &tradebookFormats[0]
+
+// dividendAliases maps the column names of dividend statements and ledgers to the dividend columns
+var dividendAliases = map[string]string{
+	"tradingsymbol":       colSymbol,
+	"scrip":               colSymbol,
+	"scheme":              colSymbol,
+	"ex_date":             colRecordDate,
+	"exdate":              colRecordDate,
+	"date":                colRecordDate,
+	"payment_date":        colPayDate,
+	"paid_on":             colPayDate,
+	"qty":                 colQuantity,
+	"units":               colQuantity,
+	"dividend_per_share":  colPerShare,
+	"dividend_per_unit":   colPerShare,
+	"net_dividend_amount": colAmount,
+	"net_amount":          colAmount,
+	"dividend_amount":     colAmount,
+}
+
+// dividendFormats are tried in order against every header row of a dividend file
+var dividendFormats = []tradebookFormat{
+	{
+		// Zerodha Console dividend statement
+		broker:     BrokerZerodha,
+		assetClass: AssetDividends,
+		detect:     []string{colISIN, "net_dividend_amount"},
+		aliases:    dividendAliases,
+		required:   []string{colSymbol, colISIN, colRecordDate, colAmount},
+	},
+	{
+		// dividend ledger kept by hand, dated by record or pay date
+		assetClass: AssetDividends,
+		detect:     []string{colRecordDate},
+		aliases:    dividendAliases,
+	},
+	{
+		assetClass: AssetDividends,
+		detect:     []string{colPayDate},
+		aliases:    dividendAliases,
+	},
+}
+
+// dividendLedger is the format of dividend ledgers kept as YAML
+var dividendLedger = &dividendFormats[1]
+
+// detectFormat returns the format of formats and column indexes of record when it
+// is the header of a known export
+func detectFormat(formats []tradebookFormat, record []string) (*tradebookFormat, map[string]int, bool) {
+	for i := range formats {
+		format := &formats[i]
+		if format.matches(record) {
+			return format, format.columns(record), true
+		}
+	}
+	return nil, nil, false
+}
+
+func (f *tradebookFormat) matches(header []string) bool {
+	names := make(map[string]struct{}, len(header))
+	for _, name := range header {
+		names[normaliseColumn(name)] = struct{}{}
+		names[f.column(name)] = struct{}{}
+	}
+	for _, name := range f.detect {
+		if _, ok := names[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *tradebookFormat) column(name string) string {
+	name = normaliseColumn(name)
+	if alias, ok := f.aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// columns maps the tradebook columns of header to their index
+func (f *tradebookFormat) columns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		column := f.column(name)
+		if _, ok := columns[column]; !ok {
+			columns[column] = i
+		}
+	}
+	return columns
+}
+
+func (f *tradebookFormat) missingColumns(columns map[string]int) []string {
+	var missing []string
+	for _, column := range f.required {
+		if _, ok := columns[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
+
+// prepareGrowwOrder derives the trade of an executed order, the order id doubles as
+// the trade id and the price is the average over the order value
+func prepareGrowwOrder(row *tradebookRow) error {
+	if status := strings.ToLower(row.get("order_status")); status != "" && status != "executed" {
+		return errIgnoredRow
+	}
+	executedAt, err := row.date(colOrderExecutionTime)
+	if err != nil {
+		return err
+	}
+	quantity, err := row.float(colQuantity)
+	if err != nil {
+		return err
+	}
+	value, err := row.float("value")
+	if err != nil {
+		return err
+	}
+	if quantity == 0 {
+		return row.errorf(colQuantity, "quantity is zero")
+	}
+	row.set(colTradeDate, executedAt.Format("2006-01-02"))
+	row.set(colTradeID, row.get(colOrderID))
+	if row.get(colPrice) == "" {
+		row.set(colPrice, strconv.FormatFloat(value/quantity, 'f', -1, 64))
+	}
+	return nil
+}
+
+// prepareGrowwFundOrder derives a trade id from the order details, the export has none
+func prepareGrowwFundOrder(row *tradebookRow) error {
+	if row.get(colTradeID) != "" {
+		return nil
+	}
+	row.set(colTradeID, row.syntheticTradeID(colISIN, colTradeDate, colTradeType, colQuantity, colPrice))
+	return nil
+}
+
+// syntheticTradeID hashes columns of a row for exports without trade ids. Identical rows of
+// a file, like two same day orders of a fund, are told apart by how often the columns were
+// seen before in the file, so the same row of overlapping exports still hashes to the same id.
+func (r *tradebookRow) syntheticTradeID(columns ...string) string {
+	var key strings.Builder
+	for _, column := range columns {
+		key.WriteString(r.get(column) + "|")
+	}
+	if r.occurrences == nil {
+		r.occurrences = make(map[string]int)
+	}
+	occurrence := r.occurrences[key.String()]
+	r.occurrences[key.String()]++
+	if occurrence > 0 {
+		key.WriteString("#" + strconv.Itoa(occurrence))
+	}
+	hash := sha1.Sum([]byte(key.String()))
+	return hex.EncodeToString(hash[:])[:16]
+}
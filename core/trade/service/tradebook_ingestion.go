@@ -0,0 +1,157 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// errDuplicateTrade is returned for a row whose trade id was already read
+var errDuplicateTrade = errors.New("duplicate trade")
+
+// DuplicateTrade is a trade id of a broker found more than once, Files lists the
+// file it was read from first followed by every file it was repeated in
+type DuplicateTrade struct {
+	Broker  string   `json:"broker"`
+	TradeID string   `json:"trade_id"`
+	Files   []string `json:"files"`
+}
+
+// SegmentIngestion describes how the tradebook of a segment was loaded
+type SegmentIngestion struct {
+	Files      []TradeFile      `json:"files"`
+	Trades     int              `json:"trades"`
+	Duplicates []DuplicateTrade `json:"duplicates"`
+	// From and To are the first and last trade dates
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Gaps are the months, as YYYY-MM, between From and To that fall between files,
+	// a file covers every month from its first to its last trade so months without
+	// trades inside a single file are not gaps
+	Gaps []string `json:"gaps"`
+}
+
+// IngestionReport describes how the tradebook of an account, or of the household, was loaded
+type IngestionReport struct {
+	LoadedAt    time.Time        `json:"loaded_at"`
+	Account     string           `json:"account"`
+	Equity      SegmentIngestion `json:"equity"`
+	MutualFunds SegmentIngestion `json:"mutual_funds"`
+	// DividendFiles are the dividend statements and ledgers read, Rows counting the dividends
+	DividendFiles []TradeFile `json:"dividend_files"`
+	// Accounts breaks the household report down by account
+	Accounts []AccountIngestion `json:"accounts,omitempty"`
+}
+
+// AccountIngestion describes how the tradebook of an account was loaded
+type AccountIngestion struct {
+	Account       string           `json:"account"`
+	Owner         string           `json:"owner"`
+	Equity        SegmentIngestion `json:"equity"`
+	MutualFunds   SegmentIngestion `json:"mutual_funds"`
+	DividendFiles []TradeFile      `json:"dividend_files"`
+}
+
+// tradeDeduper keeps the first trade read for every trade id and records the collisions
+type tradeDeduper struct {
+	firstSeen  map[string]string
+	duplicates map[string]*DuplicateTrade
+	order      []string
+}
+
+func newTradeDeduper() *tradeDeduper {
+	return &tradeDeduper{
+		firstSeen:  make(map[string]string),
+		duplicates: make(map[string]*DuplicateTrade),
+	}
+}
+
+// add returns errDuplicateTrade when tradeID of broker was already added,
+// trade ids of different brokers never collide
+func (d *tradeDeduper) add(broker, tradeID, fileName string) error {
+	key := broker + "/" + tradeID
+	first, ok := d.firstSeen[key]
+	if !ok {
+		d.firstSeen[key] = fileName
+		return nil
+	}
+	duplicate, ok := d.duplicates[key]
+	if !ok {
+		duplicate = &DuplicateTrade{Broker: broker, TradeID: tradeID, Files: []string{first}}
+		d.duplicates[key] = duplicate
+		d.order = append(d.order, key)
+	}
+	duplicate.Files = append(duplicate.Files, fileName)
+	return errDuplicateTrade
+}
+
+// collision records a trade found in more than one file that was matched by other
+// means than its trade id
+func (d *tradeDeduper) collision(broker, tradeID string, files ...string) {
+	key := broker + "/" + tradeID
+	if _, ok := d.duplicates[key]; !ok {
+		d.duplicates[key] = &DuplicateTrade{Broker: broker, TradeID: tradeID}
+		d.order = append(d.order, key)
+	}
+	d.duplicates[key].Files = append(d.duplicates[key].Files, files...)
+}
+
+// ingestion summarises the loaded files, every file must have its trade dates recorded
+func (d *tradeDeduper) ingestion(files []TradeFile) *SegmentIngestion {
+	ingestion := &SegmentIngestion{Files: files}
+	for _, key := range d.order {
+		ingestion.Duplicates = append(ingestion.Duplicates, *d.duplicates[key])
+	}
+	for _, file := range files {
+		ingestion.Trades += file.Rows
+		if file.From.IsZero() {
+			continue
+		}
+		if ingestion.From.IsZero() || file.From.Before(ingestion.From) {
+			ingestion.From = file.From
+		}
+		if file.To.After(ingestion.To) {
+			ingestion.To = file.To
+		}
+	}
+	ingestion.Gaps = filelessMonths(ingestion.From, ingestion.To, files)
+	return ingestion
+}
+
+// filelessMonths returns the months from from to to outside the first to last trade
+// date range of every file, it finds missing downloads rather than months without trades
+func filelessMonths(from, to time.Time, files []TradeFile) []string {
+	if from.IsZero() {
+		return nil
+	}
+	covered := make(map[string]struct{})
+	for _, file := range files {
+		if file.From.IsZero() {
+			continue
+		}
+		for month := startOfMonth(file.From); !month.After(file.To); month = month.AddDate(0, 1, 0) {
+			covered[month.Format("2006-01")] = struct{}{}
+		}
+	}
+	var gaps []string
+	for month := startOfMonth(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		if _, ok := covered[month.Format("2006-01")]; !ok {
+			gaps = append(gaps, month.Format("2006-01"))
+		}
+	}
+	return gaps
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// addTradeDate widens the trade date range of the file to include date
+func (f *TradeFile) addTradeDate(date time.Time) {
+	if f.From.IsZero() || date.Before(f.From) {
+		f.From = date
+	}
+	if date.After(f.To) {
+		f.To = date
+	}
+}
@@ -1,36 +1,49 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"log/slog"
 	"math"
+	"os"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
 	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
 	"github.com/pkg/errors"
 )
 
+// TradeRecord is a trade adjusted for the splits and bonuses since, the raw price
+// and quantity are set when they differ
 type TradeRecord struct {
-	Date     string  `json:"date"`
-	Price    float64 `json:"price"`
-	Quantity float64 `json:"quantity"`
-	Type     string  `json:"type"`
+	Date        string  `json:"date"`
+	Price       float64 `json:"price"`
+	Quantity    float64 `json:"quantity"`
+	Type        string  `json:"type"`
+	RawPrice    float64 `json:"raw_price,omitempty"`
+	RawQuantity float64 `json:"raw_quantity,omitempty"`
+	Charges     Charges `json:"charges"`
 }
 
 type BreakdownResponse struct {
-	Symbol          string        `json:"symbol"`
-	TotalBuyQty     float64       `json:"total_buy_qty"`
-	TotalBuyValue   float64       `json:"total_buy_value"`
-	TotalSellQty    float64       `json:"total_sell_qty"`
-	TotalSellValue  float64       `json:"total_sell_value"`
-	NetQuantity     float64       `json:"net_quantity"`
-	TotalInvestment float64       `json:"total_investment"`
-	TradeHistory    []TradeRecord `json:"trade_history"`
+	Symbol         string  `json:"symbol"`
+	TotalBuyQty    float64 `json:"total_buy_qty"`
+	TotalBuyValue  float64 `json:"total_buy_value"`
+	TotalSellQty   float64 `json:"total_sell_qty"`
+	TotalSellValue float64 `json:"total_sell_value"`
+	NetQuantity    float64 `json:"net_quantity"`
+	// TotalInvestment is the cost of the shares still held, by the configured cost basis,
+	// and RealisedGain is after charges
+	TotalInvestment float64 `json:"total_investment"`
+	RealisedGain    float64 `json:"realised_gain"`
+	// Charges totals the charges of every trade
+	Charges      Charges       `json:"charges"`
+	TradeHistory []TradeRecord `json:"trade_history"`
 }
 
 type EquityTradebook struct {
@@ -44,44 +57,234 @@ type MutualFundsTradebook struct {
 	MutualFundsTradebook map[ISIN][]MutualFundsTrade
 }
 
+// TradebookService serves the trades read from the tradefile directories of every
+// account. The tradebooks are immutable once built, a reload swaps in freshly built
+// ones. A service views either one account or, by default, all of them together.
 type TradebookService struct {
-	logger                    *slog.Logger
-	EquityTradebookCache      *EquityTradebook
-	MutualFundsTradebookCache *MutualFundsTradebook
+	logger *slog.Logger
+	// account viewed, AllAccounts for the household
+	account string
+	state   *tradebookState
 }
 
-func GetTradebookService(eqTradebookDir, mfTradebookDir string, logger *slog.Logger) (*TradebookService, error) {
-	if mfTradebookDir == "" && eqTradebookDir == "" {
-		return nil, errors.Errorf("no tradefiles directory set for equity or mutual funds")
+// tradebookState is shared by the views of every account
+type tradebookState struct {
+	accounts         []config.AccountConfig
+	snapshot         atomic.Pointer[tradebookSnapshot]
+	corporateActions atomic.Pointer[CorporateActions]
+	lineages         atomic.Pointer[SymbolLineages]
+	chargeRates      atomic.Pointer[ChargeRates]
+	costBasis        atomic.Value
+
+	// reloadMu serialises reloads
+	reloadMu sync.Mutex
+}
+
+// TradeFile is a tradebook file that contributed to the loaded tradebook
+type TradeFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Broker the file was exported from, detected from its header
+	Broker string `json:"broker"`
+	// Rows is the number of trades read from the file
+	Rows int `json:"rows"`
+	// Duplicates is the number of trades skipped as already read by trade id
+	Duplicates int `json:"duplicates"`
+	// HeaderRows and IgnoredRows are the header and preamble rows skipped
+	HeaderRows  int `json:"header_rows"`
+	IgnoredRows int `json:"ignored_rows"`
+	// Errors are the rows that were skipped
+	Errors []RowError `json:"errors"`
+	// From and To are the first and last dates of the trades in the file, duplicates included
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// occurrences counts the rows hashed to a synthetic trade id
+	occurrences map[string]int
+}
+
+type TradebookStatus struct {
+	Account          string      `json:"account"`
+	LoadedAt         time.Time   `json:"loaded_at"`
+	EquityFiles      []TradeFile `json:"equity_files"`
+	MutualFundsFiles []TradeFile `json:"mutual_funds_files"`
+	EquitySymbols    int         `json:"equity_symbols"`
+	MutualFunds      int         `json:"mutual_funds"`
+	// SkippedRows is the number of rows of all files that could not be loaded
+	SkippedRows int `json:"skipped_rows"`
+}
+
+// TradebookChanges lists what a reload added to the tradebook of the household
+type TradebookChanges struct {
+	NewShares []ScriptName
+	NewFunds  map[FundName]ISIN
+}
+
+// GetTradebookService loads the tradebooks of accounts, the service returned views all of them
+func GetTradebookService(accounts []config.AccountConfig, logger *slog.Logger) (*TradebookService, error) {
+	if err := validateAccounts(accounts); err != nil {
+		return nil, err
 	}
 
 	t := &TradebookService{
-		logger: logger,
+		logger:  logger,
+		account: AllAccounts,
+		state:   &tradebookState{accounts: accounts},
 	}
-	if mfTradebookDir != "" {
-		err := t.BuildMFTradeBook(mfTradebookDir)
-		if err != nil {
-			logger.Error("failed to get mutual funds tradebook", slog.String("error", err.Error()))
-			return nil, err
+	snapshot, err := buildSnapshot(accounts, nil)
+	if err != nil {
+		logger.Error("failed to build tradebook", slog.String("error", err.Error()))
+		return nil, err
+	}
+	t.state.snapshot.Store(snapshot)
+	t.logRowErrors()
+
+	return t, nil
+}
+
+// Reload rebuilds the tradebooks of every account from their directories and swaps
+// them in, the current tradebooks are kept when a directory can not be read
+func (t *TradebookService) Reload() (TradebookChanges, error) {
+	t.state.reloadMu.Lock()
+	defer t.state.reloadMu.Unlock()
+
+	previous := t.state.snapshot.Load().accounts[AllAccounts]
+
+	// build every tradebook before swapping any so a failed reload changes nothing
+	snapshot, err := buildSnapshot(t.state.accounts, t.state.lineages.Load())
+	if err != nil {
+		return TradebookChanges{}, errors.Wrap(err, "failed to reload tradebook")
+	}
+	t.state.snapshot.Store(snapshot)
+	t.logRowErrors()
+
+	current := snapshot.accounts[AllAccounts]
+	known := make(map[ScriptName]struct{}, len(previous.equity.AllShares))
+	for _, symbol := range previous.equity.AllShares {
+		known[symbol] = struct{}{}
+	}
+	changes := TradebookChanges{NewFunds: make(map[FundName]ISIN)}
+	for _, symbol := range current.equity.AllShares {
+		if _, ok := known[symbol]; !ok {
+			changes.NewShares = append(changes.NewShares, symbol)
 		}
 	}
+	for name, isin := range current.mutualFunds.AllFunds {
+		if _, ok := previous.mutualFunds.ISINToFundName[isin]; !ok {
+			changes.NewFunds[name] = isin
+		}
+	}
+	return changes, nil
+}
+
+func (t *TradebookService) logRowErrors() {
+	snapshot := t.state.snapshot.Load()
+	for _, account := range t.state.accounts {
+		books := snapshot.accounts[account.Name]
+		files := append(append([]TradeFile{}, books.equityIngestion.Files...), books.mutualFundsIngestion.Files...)
+		files = append(files, books.dividendFiles...)
+		for _, file := range files {
+			for _, rowErr := range file.Errors {
+				t.logger.Warn("skipped tradebook row",
+					slog.String("account", account.Name),
+					slog.String("error", rowErr.Error()))
+			}
+		}
+	}
+}
+
+// books returns the tradebooks of the viewed account
+func (t *TradebookService) books() *accountTradebook {
+	return t.state.snapshot.Load().accounts[t.account]
+}
 
-	if eqTradebookDir != "" {
-		err := t.BuildEquityTradeBook(eqTradebookDir)
+// GetIngestionReport describes the files the current tradebooks of the viewed account
+// were loaded from, the household report also breaks them down by account
+func (t *TradebookService) GetIngestionReport() IngestionReport {
+	snapshot := t.state.snapshot.Load()
+	books := snapshot.accounts[t.account]
+	report := IngestionReport{
+		LoadedAt:      snapshot.loadedAt,
+		Account:       t.account,
+		Equity:        *books.equityIngestion,
+		MutualFunds:   *books.mutualFundsIngestion,
+		DividendFiles: books.dividendFiles,
+	}
+	if t.account != AllAccounts {
+		return report
+	}
+	for _, account := range t.state.accounts {
+		books := snapshot.accounts[account.Name]
+		report.Accounts = append(report.Accounts, AccountIngestion{
+			Account:       account.Name,
+			Owner:         account.Owner,
+			Equity:        *books.equityIngestion,
+			MutualFunds:   *books.mutualFundsIngestion,
+			DividendFiles: books.dividendFiles,
+		})
+	}
+	return report
+}
+
+// GetEquityTradebook returns the current equity tradebook, it must not be modified
+func (t *TradebookService) GetEquityTradebook() *EquityTradebook {
+	return t.books().equity
+}
+
+// GetMutualFundsTradebook returns the current mutual funds tradebook, it must not be modified
+func (t *TradebookService) GetMutualFundsTradebook() *MutualFundsTradebook {
+	return t.books().mutualFunds
+}
+
+func (t *TradebookService) GetTradebookStatus() TradebookStatus {
+	snapshot := t.state.snapshot.Load()
+	books := snapshot.accounts[t.account]
+	status := TradebookStatus{
+		Account:          t.account,
+		LoadedAt:         snapshot.loadedAt,
+		EquityFiles:      books.equityIngestion.Files,
+		MutualFundsFiles: books.mutualFundsIngestion.Files,
+		EquitySymbols:    len(books.equity.AllShares),
+		MutualFunds:      len(books.mutualFunds.AllFunds),
+	}
+	for _, files := range [][]TradeFile{status.EquityFiles, status.MutualFundsFiles} {
+		for _, file := range files {
+			status.SkippedRows += len(file.Errors)
+		}
+	}
+	return status
+}
+
+// tradeFiles lists the files in a tradebook directory
+func tradeFiles(tradebookDir string) ([]TradeFile, error) {
+	fileNames, err := utils.ReadDir(tradebookDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]TradeFile, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		info, err := os.Stat(fileName)
 		if err != nil {
-			logger.Error("failed to build equity tradebook", slog.String("error", err.Error()))
 			return nil, err
 		}
+		files = append(files, TradeFile{Name: fileName, Size: info.Size(), ModTime: info.ModTime()})
 	}
-
-	return t, nil
+	return files, nil
 }
 
-func (t *TradebookService) BuildMFTradeBook(tradebookDir string) error {
-	var mutualFundsTradebookCache MutualFundsTradebook
-	tradeMap, allFunds, err := readMFTradeFiles(tradebookDir)
+func buildMFTradebook(tradebookDir, account string) (*MutualFundsTradebook, *SegmentIngestion, error) {
+	files, err := tradeFiles(tradebookDir)
 	if err != nil {
-		return errors.Wrap(err, "unable to read MF trade file")
+		return nil, nil, errors.Wrap(err, "unable to read MF trade file")
+	}
+
+	var mutualFundsTradebookCache MutualFundsTradebook
+	tradeMap, allFunds, ingestion := readMFTradeFiles(files)
+	for _, trades := range tradeMap {
+		for i := range trades {
+			trades[i].Account = account
+		}
 	}
 	mutualFundsTradebookCache.MutualFundsTradebook = tradeMap
 	mutualFundsTradebookCache.AllFunds = allFunds
@@ -89,155 +292,283 @@ func (t *TradebookService) BuildMFTradeBook(tradebookDir string) error {
 	for k, v := range allFunds {
 		mutualFundsTradebookCache.ISINToFundName[v] = k
 	}
-	t.MutualFundsTradebookCache = &mutualFundsTradebookCache
-
-	return nil
+	return &mutualFundsTradebookCache, ingestion, nil
 }
 
-// explain the purpose of this function
-func readMFTradeFiles(tradebookDir string) (map[ISIN][]MutualFundsTrade, map[FundName]ISIN, error) {
+// readMFTradeFiles builds the trades of every fund from the tradebook files, trades
+// repeated across files are read once. The rows loaded and rejected are recorded on files.
+func readMFTradeFiles(files []TradeFile) (map[ISIN][]MutualFundsTrade, map[FundName]ISIN, *SegmentIngestion) {
 	// to remove duplidate trade ids
-	tradeSet := make(map[string]struct{})
-	allFunds := make(map[FundName]ISIN)
+	deduper := newTradeDeduper()
 
-	tradeFiles, err := utils.ReadDir(tradebookDir)
-	if err != nil {
-		return nil, nil, err
-	}
-	tradebookCSV, err := utils.ReadCSV(tradeFiles)
-	if err != nil {
-		return nil, nil, err
+	var (
+		trades  []MutualFundsTrade
+		names   []FundName
+		sources []*TradeFile
+	)
+	for i := range files {
+		file := &files[i]
+		readTradebookFile(file, AssetMutualFunds, func(row tradebookRow) error {
+			trade, err := parseMFTrade(row)
+			if err != nil {
+				return err
+			}
+			// duplicates still count towards the dates the file covers
+			file.addTradeDate(trade.TradeDate)
+			if err := deduper.add(trade.Broker, trade.TradeID, file.Name); err != nil {
+				return err
+			}
+			trades = append(trades, trade)
+			names = append(names, FundName(row.get(colSymbol)))
+			sources = append(sources, file)
+			return nil
+		})
 	}
-	tradebook := make(map[ISIN][]MutualFundsTrade)
-	// avoid magic numbers, instead of 10 it should be index of trade_id
-	for _, record := range tradebookCSV {
-		if record[0] == "symbol" { //suh handling shoul dnot be needed, instead handle using skip header
-			continue
-		}
-		if _, ok := tradeSet[record[10]]; ok {
-			continue
-		}
-		tradeSet[record[10]] = struct{}{}
 
-		symbol := FundName(record[0])
-		isin := ISIN(record[1])
-		allFunds[symbol] = isin
-		if _, ok := tradebook[isin]; !ok {
-			tradebook[isin] = []MutualFundsTrade{}
+	// a CAS also lists the trades placed through brokers
+	duplicates := casDuplicates(trades)
+	for i := range trades {
+		j, ok := duplicates[i]
+		if !ok {
+			continue
 		}
+		sources[i].Rows--
+		sources[i].Duplicates++
+		deduper.collision(trades[i].Broker, trades[i].TradeID, sources[j].Name, sources[i].Name)
+	}
 
-		tradeTime, err := time.Parse(time.DateOnly, record[2])
-		if err != nil {
-			return nil, nil, err
+	tradebook := make(map[ISIN][]MutualFundsTrade)
+	fundNames := make(map[ISIN]FundName)
+	fundNameFromCAS := make(map[ISIN]bool)
+	for i, trade := range trades {
+		if _, ok := duplicates[i]; ok {
+			continue
 		}
-
-		quantityString := record[8]
-		quantity, err := strconv.ParseFloat(quantityString, 64)
-		if err != nil {
-			return nil, nil, err
+		isin := ISIN(trade.Isin)
+		// statements name funds with their plan and option, the broker's name is kept when there is one
+		if _, ok := fundNames[isin]; !ok || (fundNameFromCAS[isin] && trade.Broker != BrokerCAS) {
+			fundNames[isin] = names[i]
+			fundNameFromCAS[isin] = trade.Broker == BrokerCAS
 		}
-
-		priceString := record[9]
-		price, err := strconv.ParseFloat(priceString, 64)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		tradebook[isin] = append(tradebook[isin], MutualFundsTrade{
-			Isin:               record[1],
-			TradeDate:          tradeTime,
-			Exchange:           record[3],
-			Segment:            record[4],
-			Series:             record[5],
-			TradeType:          record[6],
-			Auction:            record[7],
-			Quantity:           quantity,
-			Price:              price,
-			TradeID:            record[10],
-			OrderID:            record[11],
-			OrderExecutionTime: record[12],
-		})
-
+		tradebook[isin] = append(tradebook[isin], trade)
+	}
+	allFunds := make(map[FundName]ISIN, len(fundNames))
+	for isin, name := range fundNames {
+		allFunds[name] = isin
 	}
 
 	for isin := range tradebook {
-		sort.Slice(tradebook[isin], func(i, j int) bool {
+		sort.SliceStable(tradebook[isin], func(i, j int) bool {
 			return tradebook[isin][i].TradeDate.Before(tradebook[isin][j].TradeDate)
 		})
 	}
 
-	return tradebook, allFunds, nil
+	return tradebook, allFunds, deduper.ingestion(files)
 }
 
-func (t *TradebookService) BuildEquityTradeBook(tradebookDir string) error {
-	tradeMap, err := readEquityTradeFiles(tradebookDir)
+func parseMFTrade(row tradebookRow) (MutualFundsTrade, error) {
+	if _, err := row.required(colSymbol); err != nil {
+		return MutualFundsTrade{}, err
+	}
+	isin, err := row.required(colISIN)
 	if err != nil {
-		return err
+		return MutualFundsTrade{}, err
 	}
+	tradeID, err := row.required(colTradeID)
+	if err != nil {
+		return MutualFundsTrade{}, err
+	}
+	tradeType, err := row.tradeType()
+	if err != nil {
+		return MutualFundsTrade{}, err
+	}
+	tradeTime, err := row.day(colTradeDate)
+	if err != nil {
+		return MutualFundsTrade{}, err
+	}
+	quantity, err := row.float(colQuantity)
+	if err != nil {
+		return MutualFundsTrade{}, err
+	}
+	price, err := row.float(colPrice)
+	if err != nil {
+		return MutualFundsTrade{}, err
+	}
+	amount := quantity * price
+	if row.get(colAmount) != "" {
+		if amount, err = row.float(colAmount); err != nil {
+			return MutualFundsTrade{}, err
+		}
+	}
+
+	return MutualFundsTrade{
+		Isin:               isin,
+		TradeDate:          tradeTime,
+		Exchange:           row.get(colExchange),
+		Segment:            row.get(colSegment),
+		Series:             row.get(colSeries),
+		TradeType:          tradeType,
+		Auction:            row.get(colAuction),
+		Quantity:           quantity,
+		Price:              price,
+		TradeID:            tradeID,
+		OrderID:            row.get(colOrderID),
+		OrderExecutionTime: row.get(colOrderExecutionTime),
+		Broker:             row.broker,
+		Folio:              row.get(colFolio),
+		Amount:             amount,
+		Transaction:        row.get(casTransactionColumn),
+	}, nil
+}
+
+func buildEquityTradebook(tradebookDir, account string) (*EquityTradebook, *SegmentIngestion, error) {
+	files, err := tradeFiles(tradebookDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tradeMap, ingestion := readEquityTradeFiles(files)
 
 	var trickers []ScriptName
-	for fundName := range tradeMap {
+	for fundName, trades := range tradeMap {
 		trickers = append(trickers, fundName)
+		for i := range trades {
+			trades[i].Account = account
+		}
 	}
 
 	var EquityTradebookCache EquityTradebook
 	EquityTradebookCache.AllShares = trickers
 	EquityTradebookCache.EquityTradebook = tradeMap
 
-	t.EquityTradebookCache = &EquityTradebookCache
-	return nil
+	return &EquityTradebookCache, ingestion, nil
+}
+
+// SetCorporateActions sets the splits and bonuses equity trades are adjusted for
+func (t *TradebookService) SetCorporateActions(actions *CorporateActions) {
+	t.state.corporateActions.Store(actions)
+}
+
+// SetChargeRates sets the rate table trades are charged by, trades are free of charges without one
+func (t *TradebookService) SetChargeRates(rates *ChargeRates) {
+	t.state.chargeRates.Store(rates)
+}
+
+// GetAdjustedEquityTrades returns copies of the trades of symbol with their quantity
+// and price adjusted for the splits and bonuses since and their charges set, the
+// tradebook keeps the raw trades
+func (t *TradebookService) GetAdjustedEquityTrades(symbol ScriptName) []EquityTrade {
+	return t.adjustTrades(t.GetEquityTradebook().EquityTradebook[symbol])
+}
+
+// adjustTrades returns adjusted copies of raw, see GetAdjustedEquityTrades
+func (t *TradebookService) adjustTrades(raw []EquityTrade) []EquityTrade {
+	actions := t.state.corporateActions.Load()
+	trades := make([]EquityTrade, len(raw))
+	for i, trade := range raw {
+		trades[i] = actions.AdjustTrade(trade)
+	}
+	t.state.chargeRates.Load().Apply(trades)
+	return trades
 }
 
 func (t *TradebookService) GetMutualFundsList() map[FundName]ISIN {
-	return t.MutualFundsTradebookCache.AllFunds
+	return t.GetMutualFundsTradebook().AllFunds
 }
 
 func (t *TradebookService) GetEquityList() []ScriptName {
-	return t.EquityTradebookCache.AllShares
+	return t.GetEquityTradebook().AllShares
 }
 
-func readEquityTradeFiles(tradebookDir string) (map[ScriptName][]EquityTrade, error) {
+// readEquityTradeFiles builds the trades of every symbol from the tradebook files, trades
+// repeated across files are read once. The rows loaded and rejected are recorded on files.
+func readEquityTradeFiles(files []TradeFile) (map[ScriptName][]EquityTrade, *SegmentIngestion) {
 	// to remove duplidate trade ids
-	tradeSet := make(map[string]struct{})
+	deduper := newTradeDeduper()
+	tradebook := make(map[ScriptName][]EquityTrade)
+
+	for i := range files {
+		file := &files[i]
+		readTradebookFile(file, AssetEquity, func(row tradebookRow) error {
+			trade, err := parseEquityTrade(row)
+			if err != nil {
+				return err
+			}
+			// duplicates still count towards the dates the file covers
+			file.addTradeDate(trade.TradeDate)
+			if err := deduper.add(trade.Broker, trade.TradeId, file.Name); err != nil {
+				return err
+			}
+
+			symbol := ScriptName(trade.Symbol)
+			tradebook[symbol] = append(tradebook[symbol], trade)
+			return nil
+		})
+	}
+
+	for _, trades := range tradebook {
+		sortEquityTrades(trades)
+	}
+	return tradebook, deduper.ingestion(files)
+}
 
-	tradeFiles, err := utils.ReadDir(tradebookDir)
+// sortEquityTrades orders trades by trade date and execution time
+func sortEquityTrades(trades []EquityTrade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].TradeDate.Equal(trades[j].TradeDate) {
+			return trades[i].TradeDate.Before(trades[j].TradeDate)
+		}
+		return trades[i].OrderExecutionTime < trades[j].OrderExecutionTime
+	})
+}
+
+func parseEquityTrade(row tradebookRow) (EquityTrade, error) {
+	symbol, err := row.required(colSymbol)
 	if err != nil {
-		return nil, err
+		return EquityTrade{}, err
 	}
-	tradebookCSV, err := utils.ReadCSV(tradeFiles)
+	tradeID, err := row.required(colTradeID)
 	if err != nil {
-		return nil, err
+		return EquityTrade{}, err
 	}
-	tradebook := make(map[ScriptName][]EquityTrade)
-	for _, record := range tradebookCSV {
-		if record[1] == "symbol" {
-			continue
-		}
-		if _, ok := tradeSet[record[10]]; ok {
-			continue
-		}
-		tradeSet[record[10]] = struct{}{}
-
-		symbol := ScriptName(record[0])
-		if _, ok := tradebook[symbol]; !ok {
-			tradebook[symbol] = []EquityTrade{}
-		}
-		tradebook[symbol] = append(tradebook[symbol], EquityTrade{
-			Symbol:             record[1],
-			TradeDate:          record[2],
-			Exchange:           record[3],
-			Segment:            record[4],
-			TradeType:          record[6],
-			Quantity:           record[8],
-			Price:              record[9],
-			OrderExecutionTime: record[12],
-		})
+	tradeType, err := row.tradeType()
+	if err != nil {
+		return EquityTrade{}, err
+	}
+	tradeDate, err := row.day(colTradeDate)
+	if err != nil {
+		return EquityTrade{}, err
+	}
+	quantity, err := row.float(colQuantity)
+	if err != nil {
+		return EquityTrade{}, err
 	}
-	return tradebook, nil
+	price, err := row.float(colPrice)
+	if err != nil {
+		return EquityTrade{}, err
+	}
+
+	return EquityTrade{
+		// not every broker exports the ISIN
+		Isin:               row.get(colISIN),
+		Symbol:             strings.ToUpper(symbol),
+		TradeDate:          tradeDate,
+		Exchange:           row.get(colExchange),
+		Segment:            row.get(colSegment),
+		Series:             row.get(colSeries),
+		TradeType:          tradeType,
+		Auction:            row.get(colAuction),
+		Quantity:           quantity,
+		Price:              price,
+		TradeId:            tradeID,
+		OrderId:            row.get(colOrderID),
+		OrderExecutionTime: row.get(colOrderExecutionTime),
+		Broker:             row.broker,
+	}, nil
 }
 
 func (t *TradebookService) GetPriceMFPositionsInTimeRange(symbol string, from, to time.Time) []models.MFHoldingsData {
-	requestedRange := t.MutualFundsTradebookCache.MutualFundsTradebook[ISIN(symbol)]
+	requestedRange := t.GetMutualFundsTradebook().MutualFundsTradebook[ISIN(symbol)]
 	if len(requestedRange) == 0 {
 		return nil
 	}
@@ -267,7 +598,7 @@ func (t *TradebookService) GetPriceMFPositionsInTimeRange(symbol string, from, t
 
 // todo: this should be removed
 func (t *TradebookService) getCAGR(isin ISIN, from, to time.Time) float64 {
-	priceHistory := t.MutualFundsTradebookCache.MutualFundsTradebook[isin]
+	priceHistory := t.GetMutualFundsTradebook().MutualFundsTradebook[isin]
 	if len(priceHistory) == 0 {
 		return 0
 	}
@@ -284,21 +615,27 @@ func (t *TradebookService) getCAGR(isin ISIN, from, to time.Time) float64 {
 	return cagr
 }
 
-// todo: this should be removed
+// getXIRR is the XIRR of the investments in a fund since from, valued at currentValue on to.
+// Purchases are cash going out, redemptions and IDCW payouts cash coming in. Every trade is
+// its amount, units times NAV, the NAV alone left the cash flows unweighted by trade size and
+// off the scale of currentValue and the payouts.
 func (t *TradebookService) getXIRR(isin ISIN, from, to time.Time, currentValue float64) float64 {
-	tradeHistory := t.MutualFundsTradebookCache.MutualFundsTradebook[isin]
+	tradeHistory := t.GetMutualFundsTradebook().MutualFundsTradebook[isin]
 	startIndex := utils.MomentBinarySearch(tradeHistory, from)
 	array := []MutualFundsTrade{}
 	for _, v := range tradeHistory[startIndex:] {
+		amount := v.Price * v.Quantity
 		if v.TradeType == "sell" {
-			array = append(array, MutualFundsTrade{
-				TradeDate: v.GetTime(),
-				Price:     -v.GetPrice(),
-			})
-			continue
+			amount = -amount
 		}
-		array = append(array, v)
+		array = append(array, MutualFundsTrade{TradeDate: v.GetTime(), Price: amount})
 	}
+	for _, dividend := range t.fundDividends(isin, from, to) {
+		array = append(array, MutualFundsTrade{TradeDate: dividend.Date(), Price: -dividend.Amount})
+	}
+	sort.SliceStable(array, func(i, j int) bool {
+		return array[i].TradeDate.Before(array[j].TradeDate)
+	})
 	array = append(array, MutualFundsTrade{
 		TradeDate: to,
 		Price:     -currentValue,
@@ -309,8 +646,9 @@ func (t *TradebookService) getXIRR(isin ISIN, from, to time.Time, currentValue f
 
 func (t *TradebookService) GetMFSummmary(from, to time.Time) []models.MFSummary {
 	var summary []models.MFSummary
-	for isin, trades := range t.MutualFundsTradebookCache.MutualFundsTradebook {
-		fmt.Println("starting calculation for ", string(t.GetFundNameFromISIN(isin)))
+	mutualFunds := t.GetMutualFundsTradebook()
+	for isin, trades := range mutualFunds.MutualFundsTradebook {
+		t.logger.Debug("calculating fund summary", slog.String("fund", string(t.GetFundNameFromISIN(isin))))
 		if len(trades) == 0 {
 			continue
 		}
@@ -342,9 +680,9 @@ func (t *TradebookService) GetMFSummmary(from, to time.Time) []models.MFSummary
 			}
 		}
 
-		priceHistory := t.MutualFundsTradebookCache.MutualFundsTradebook[isin]
+		priceHistory := mutualFunds.MutualFundsTradebook[isin]
 		if len(priceHistory) == 0 {
-			log.Printf("unable to compute summary, price history not found for %s", isin)
+			t.logger.Warn("unable to compute summary, price history not found", slog.String("isin", string(isin)))
 			continue
 		}
 		currentPrice := float64(priceHistory[len(priceHistory)-1].Price)
@@ -363,7 +701,8 @@ func (t *TradebookService) GetMFSummmary(from, to time.Time) []models.MFSummary
 			cagr = t.getCAGR(isin, *holdingSince, time.Now())
 			xirr = t.getXIRR(isin, *holdingSince, time.Now(), currentValue)
 		}
-		fmt.Println(string(t.GetFundNameFromISIN(isin)), cagr, xirr)
+		t.logger.Debug("calculated fund summary",
+			slog.String("fund", string(t.GetFundNameFromISIN(isin))), slog.Float64("cagr", cagr), slog.Float64("xirr", xirr))
 		s := models.MFSummary{
 			Name:                  string(t.GetFundNameFromISIN(isin)),
 			ISIN:                  string(isin),
@@ -376,6 +715,9 @@ func (t *TradebookService) GetMFSummmary(from, to time.Time) []models.MFSummary
 			CAGR:                  cagr,
 			XIRR:                  xirr,
 		}
+		for _, dividend := range t.fundDividends(isin, time.Time{}, time.Now()) {
+			s.Dividends += dividend.Amount
+		}
 		if (currentValue-currentInvested) != 0 && currentInvested != 0 {
 			s.AllTimeAbsoluteReturnPercentage = ((currentValue - currentInvested) / currentInvested) * 100
 		}
@@ -385,33 +727,37 @@ func (t *TradebookService) GetMFSummmary(from, to time.Time) []models.MFSummary
 }
 
 func (t *TradebookService) GetFundNameFromISIN(k ISIN) FundName {
-	return t.MutualFundsTradebookCache.ISINToFundName[k]
+	return t.GetMutualFundsTradebook().ISINToFundName[k]
 }
 
 func (t *TradebookService) GetEqBreakdown(symbol string) (BreakdownResponse, error) {
-	script := ScriptName(strings.ToUpper(symbol))
-	trades, ok := t.EquityTradebookCache.EquityTradebook[script]
+	// the holding of a renamed or merged symbol is under its successor
+	script := t.state.lineages.Load().Current(ScriptName(strings.ToUpper(symbol)))
+	// the raw and adjusted trades are matched by index, so both come from one snapshot
+	raw, ok := t.GetEquityTradebook().EquityTradebook[script]
 	if !ok {
 		return BreakdownResponse{}, fmt.Errorf("no data for symbol: %s", symbol)
 	}
+	trades := t.adjustTrades(raw)
 
 	var (
 		buyQty, sellQty, buyValue, sellValue float64
+		charges                              Charges
 		history                              []TradeRecord
 	)
 
-	for _, trade := range trades {
-		price, err1 := strconv.ParseFloat(trade.Price, 64)
-		qty, err2 := strconv.ParseFloat(trade.Quantity, 64)
-		if err1 != nil || err2 != nil {
-			continue
-		}
-
+	for i, trade := range trades {
+		price, qty := trade.Price, trade.Quantity
 		record := TradeRecord{
-			Date:     trade.TradeDate,
+			Date:     trade.TradeDate.Format(time.DateOnly),
 			Price:    price,
 			Quantity: qty,
-			Type:     strings.ToLower(trade.TradeType),
+			Type:     trade.TradeType,
+			Charges:  trade.Charges,
+		}
+		charges = charges.add(trade.Charges)
+		if qty != raw[i].Quantity {
+			record.RawPrice, record.RawQuantity = raw[i].Price, raw[i].Quantity
 		}
 		history = append(history, record)
 
@@ -426,12 +772,7 @@ func (t *TradebookService) GetEqBreakdown(symbol string) (BreakdownResponse, err
 	}
 
 	netQty := buyQty - sellQty
-	avgBuy := 0.0
-	totalInvestment := 0.0
-	if netQty > 0 {
-		avgBuy = buyValue / buyQty
-		totalInvestment = netQty * avgBuy
-	}
+	pnl := t.symbolPnL(script, nil, time.Now())
 
 	return BreakdownResponse{
 		Symbol:          symbol,
@@ -440,7 +781,84 @@ func (t *TradebookService) GetEqBreakdown(symbol string) (BreakdownResponse, err
 		TotalSellQty:    sellQty,
 		TotalSellValue:  sellValue,
 		NetQuantity:     netQty,
-		TotalInvestment: totalInvestment,
+		TotalInvestment: pnl.Cost,
+		RealisedGain:    pnl.Realised,
+		Charges:         charges,
 		TradeHistory:    history,
 	}, nil
 }
+
+// Watch polls the tradefile directories of every account every interval and reloads the tradebooks
+// when a file was added, removed or modified, onReload is called after every reload
+func (t *TradebookService) Watch(ctx context.Context, interval time.Duration, onReload func(TradebookChanges)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := t.changedOnDisk()
+		if err != nil {
+			t.logger.Error("failed to check tradebook directories", slog.String("error", err.Error()))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		changes, err := t.Reload()
+		if err != nil {
+			t.logger.Error("failed to reload tradebook", slog.String("error", err.Error()))
+			continue
+		}
+		t.logger.Info("reloaded tradebook",
+			slog.Int("new_shares", len(changes.NewShares)),
+			slog.Int("new_funds", len(changes.NewFunds)))
+		if onReload != nil {
+			onReload(changes)
+		}
+	}
+}
+
+// changedOnDisk reports whether the files in the tradefile directories of any account
+// differ from the files the current tradebooks were built from
+func (t *TradebookService) changedOnDisk() (bool, error) {
+	snapshot := t.state.snapshot.Load()
+	for _, account := range t.state.accounts {
+		books := snapshot.accounts[account.Name]
+		dirs := []struct {
+			dir    string
+			loaded []TradeFile
+		}{
+			{account.EquityTradeFilesDirectory, books.equityIngestion.Files},
+			{account.MutualFundsTradeFilesDirectory, books.mutualFundsIngestion.Files},
+			{account.DividendsDirectory, books.dividendFiles},
+		}
+		for _, d := range dirs {
+			if d.dir == "" {
+				continue
+			}
+			files, err := tradeFiles(d.dir)
+			if err != nil {
+				return false, err
+			}
+			if !sameTradeFiles(files, d.loaded) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func sameTradeFiles(a, b []TradeFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Size != b[i].Size || !a[i].ModTime.Equal(b[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
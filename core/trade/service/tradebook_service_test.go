@@ -0,0 +1,470 @@
+package service_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/config"
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/service"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTradeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path.Join(dir, name), []byte(content), 0o644))
+}
+
+// getTradebookService loads a tradebook with the default account only
+func getTradebookService(t *testing.T, eqDir, mfDir string) *service.TradebookService {
+	t.Helper()
+	tradebook, err := service.GetTradebookService([]config.AccountConfig{{
+		Name:                           config.DefaultAccount,
+		EquityTradeFilesDirectory:      eqDir,
+		MutualFundsTradeFilesDirectory: mfDir,
+	}}, slog.Default())
+	require.NoError(t, err)
+	return tradebook
+}
+
+func TestTradebookReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2023.csv",
+		"INFY,INE009A01021,2023-04-03,NSE,EQ,EQ,buy,false,10,1400.5,1,101,2023-04-03T09:20:11\n")
+
+	tradebook := getTradebookService(t, dir, "")
+	assert.ElementsMatch(t, []service.ScriptName{"INFY"}, tradebook.GetEquityList())
+
+	status := tradebook.GetTradebookStatus()
+	require.Len(t, status.EquityFiles, 1)
+	assert.Equal(t, path.Join(dir, "2023.csv"), status.EquityFiles[0].Name)
+	assert.Empty(t, status.MutualFundsFiles)
+	loadedAt := status.LoadedAt
+
+	writeTradeFile(t, dir, "2024.csv",
+		"TCS,INE467B01029,2024-05-06,NSE,EQ,EQ,buy,false,2,3900,2,102,2024-05-06T10:01:02\n"+
+			"INFY,INE009A01021,2024-05-06,NSE,EQ,EQ,buy,false,5,1450,3,103,2024-05-06T10:05:00\n")
+
+	previous := tradebook.GetEquityTradebook()
+	changes, err := tradebook.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []service.ScriptName{"TCS"}, changes.NewShares)
+	assert.Empty(t, changes.NewFunds)
+	assert.ElementsMatch(t, []service.ScriptName{"INFY", "TCS"}, tradebook.GetEquityList())
+	assert.Len(t, tradebook.GetEquityTradebook().EquityTradebook["INFY"], 2)
+	// the tradebook handed out earlier is left untouched
+	assert.Len(t, previous.EquityTradebook["INFY"], 1)
+
+	status = tradebook.GetTradebookStatus()
+	assert.Len(t, status.EquityFiles, 2)
+	assert.Equal(t, 2, status.EquitySymbols)
+	assert.False(t, status.LoadedAt.Before(loadedAt))
+}
+
+func TestTradebookReloadKeepsTradebookOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2023.csv",
+		"INFY,INE009A01021,2023-04-03,NSE,EQ,EQ,buy,false,10,1400.5,1,101,2023-04-03T09:20:11\n")
+
+	tradebook := getTradebookService(t, dir, "")
+
+	require.NoError(t, os.RemoveAll(dir))
+	_, err := tradebook.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, []service.ScriptName{"INFY"}, tradebook.GetEquityList())
+	assert.Len(t, tradebook.GetTradebookStatus().EquityFiles, 1)
+}
+
+func TestTradebookColumnsByHeader(t *testing.T) {
+	dir := t.TempDir()
+	// columns reordered and renamed, preceded by a preamble
+	writeTradeFile(t, dir, "2019.csv", "Client ID,AB1234\n"+
+		"\n"+
+		"Trade ID,Trade Date,Symbol,ISIN,Type,Qty,Price\n"+
+		"11,2019-06-10,ABC Fund,INF000000001,buy,10.5,20\n"+
+		"12,10-07-2019,ABC Fund,INF000000001,buy,2,21.5\n")
+	writeTradeFile(t, dir, "2024.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"ABC Fund,INF000000001,2024-01-02,BSE,MF,,sell,false,4,30,21,201,2024-01-02T10:00:00\n"+
+			"ABC Fund,INF000000001,2024-13-02,BSE,MF,,sell,false,1,30,22,202,2024-01-02T10:00:00\n"+
+			"ABC Fund,INF000000001,2024-01-03,BSE,MF,,sell,false,one,30,23,203,2024-01-03T10:00:00\n"+
+			"ABC Fund,INF000000001,2024-01-02,BSE,MF,,sell,false,4,30,21,201,2024-01-02T10:00:00\n")
+	writeTradeFile(t, dir, "broken.csv", "symbol,isin,trade_date,quantity,price,trade_id\n"+
+		"ABC Fund,INF000000001,2024-01-02,4,30,31\n")
+
+	tradebook := getTradebookService(t, "", dir)
+
+	trades := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF000000001"]
+	require.Len(t, trades, 3)
+	assert.Equal(t, 10.5, trades[0].Quantity)
+	assert.Equal(t, time.Date(2019, 7, 10, 0, 0, 0, 0, time.UTC), trades[1].TradeDate)
+	assert.Equal(t, "sell", trades[2].TradeType)
+	assert.Equal(t, "BSE", trades[2].Exchange)
+	assert.Equal(t, map[service.FundName]service.ISIN{"ABC Fund": "INF000000001"}, tradebook.GetMutualFundsList())
+
+	files := tradebook.GetTradebookStatus().MutualFundsFiles
+	require.Len(t, files, 3)
+	assert.Equal(t, 2, files[0].Rows)
+	assert.Empty(t, files[0].Errors)
+
+	assert.Equal(t, 1, files[1].Rows)
+	assert.Equal(t, 1, files[1].Duplicates)
+	require.Len(t, files[1].Errors, 2)
+	assert.Equal(t, service.RowError{File: path.Join(dir, "2024.csv"), Line: 3, Column: "trade_date",
+		Message: `invalid date "2024-13-02"`}, files[1].Errors[0])
+	assert.Equal(t, 4, files[1].Errors[1].Line)
+	assert.Equal(t, "quantity", files[1].Errors[1].Column)
+
+	assert.Zero(t, files[2].Rows)
+	require.Len(t, files[2].Errors, 1)
+	assert.Equal(t, 1, files[2].Errors[0].Line)
+	assert.Contains(t, files[2].Errors[0].Message, "missing required columns trade_type")
+}
+
+func TestTradebookWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2023.csv",
+		"INFY,INE009A01021,2023-04-03,NSE,EQ,EQ,buy,false,10,1400.5,1,101,2023-04-03T09:20:11\n")
+
+	tradebook := getTradebookService(t, dir, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan service.TradebookChanges, 1)
+	go tradebook.Watch(ctx, 10*time.Millisecond, func(changes service.TradebookChanges) {
+		reloads <- changes
+	})
+
+	writeTradeFile(t, dir, "2024.csv",
+		"TCS,INE467B01029,2024-05-06,NSE,EQ,EQ,buy,false,2,3900,2,102,2024-05-06T10:01:02\n")
+
+	select {
+	case changes := <-reloads:
+		assert.Equal(t, []service.ScriptName{"TCS"}, changes.NewShares)
+	case <-time.After(2 * time.Second):
+		t.Fatal("tradebook was not reloaded")
+	}
+}
+
+func TestEquityTradesAreTyped(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2024.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"INFY,INE009A01021,2024-05-06,NSE,EQ,EQ,SELL,false,5,1450.25,3,103,2024-05-06T14:05:00\n"+
+			"INFY,INE009A01021,2023-04-03,NSE,EQ,EQ,buy,false,10,1400.5,1,101,2023-04-03T09:20:11\n"+
+			"INFY,INE009A01021,2024-05-06,NSE,EQ,EQ,buy,true,2,1440,2,102,2024-05-06T09:30:00\n"+
+			"INFY,INE009A01021,2024-05-07,NSE,EQ,EQ,buy,false,1.5.0,1440,4,104,2024-05-07T09:30:00\n"+
+			"INFY,INE009A01021,2024-05-08,NSE,EQ,EQ,gift,false,1,1440,5,105,2024-05-08T09:30:00\n")
+
+	tradebook := getTradebookService(t, dir, "")
+
+	trades := tradebook.GetEquityTradebook().EquityTradebook["INFY"]
+	require.Len(t, trades, 3)
+	assert.Equal(t, []string{"1", "2", "3"}, []string{trades[0].TradeId, trades[1].TradeId, trades[2].TradeId})
+	assert.Equal(t, service.EquityTrade{
+		Isin:               "INE009A01021",
+		Symbol:             "INFY",
+		TradeDate:          time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		Exchange:           "NSE",
+		Segment:            "EQ",
+		Series:             "EQ",
+		TradeType:          "sell",
+		Auction:            "false",
+		Quantity:           5,
+		Price:              1450.25,
+		TradeId:            "3",
+		OrderId:            "103",
+		OrderExecutionTime: "2024-05-06T14:05:00",
+		Broker:             service.BrokerZerodha,
+		Account:            config.DefaultAccount,
+	}, trades[2])
+
+	status := tradebook.GetTradebookStatus()
+	assert.Equal(t, 2, status.SkippedRows)
+	rowErrors := status.EquityFiles[0].Errors
+	require.Len(t, rowErrors, 2)
+	assert.Equal(t, 5, rowErrors[0].Line)
+	assert.Equal(t, "quantity", rowErrors[0].Column)
+	assert.Equal(t, 6, rowErrors[1].Line)
+	assert.Equal(t, "trade_type", rowErrors[1].Column)
+
+	breakdown, err := tradebook.GetEqBreakdown("infy")
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, breakdown.TotalBuyQty)
+	assert.Equal(t, 5.0, breakdown.TotalSellQty)
+	assert.Equal(t, "2023-04-03", breakdown.TradeHistory[0].Date)
+}
+
+func TestTradebookIngestionReport(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "2022.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"INFY,INE009A01021,2022-04-04,NSE,EQ,EQ,buy,false,10,1400,1,101,2022-04-04T09:20:11\n"+
+			"INFY,INE009A01021,2022-06-20,NSE,EQ,EQ,buy,false,10,1300,2,102,2022-06-20T09:20:11\n")
+	writeTradeFile(t, dir, "2022-again.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"INFY,INE009A01021,2022-06-20,NSE,EQ,EQ,buy,false,10,1300,2,102,2022-06-20T09:20:11\n")
+	writeTradeFile(t, dir, "2023.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"TCS,INE467B01029,2022-10-03,NSE,EQ,EQ,buy,false,1,3000,3,103,2022-10-03T10:01:02\n"+
+			"TCS,INE467B01029,2022-11-15,NSE,EQ,EQ,sell,false,1,3300,4,104,2022-11-15T10:01:02\n")
+
+	tradebook := getTradebookService(t, dir, "")
+
+	report := tradebook.GetIngestionReport().Equity
+	assert.Equal(t, 4, report.Trades)
+	assert.Equal(t, time.Date(2022, 4, 4, 0, 0, 0, 0, time.UTC), report.From)
+	assert.Equal(t, time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC), report.To)
+	assert.Equal(t, []service.DuplicateTrade{
+		{Broker: service.BrokerZerodha, TradeID: "2", Files: []string{path.Join(dir, "2022-again.csv"), path.Join(dir, "2022.csv")}},
+	}, report.Duplicates)
+	assert.Equal(t, []string{"2022-07", "2022-08", "2022-09"}, report.Gaps)
+
+	// files are read in name order
+	require.Len(t, report.Files, 3)
+	again := report.Files[0]
+	assert.Equal(t, path.Join(dir, "2022-again.csv"), again.Name)
+	assert.Equal(t, 1, again.Rows)
+	assert.Equal(t, 1, again.HeaderRows)
+	assert.Zero(t, again.Duplicates)
+	assert.Equal(t, 1, report.Files[1].Duplicates)
+	assert.Equal(t, 1, report.Files[1].Rows)
+}
+
+func TestTradebookBrokerFormats(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "groww.csv",
+		"Stock name,Symbol,ISIN,Type,Quantity,Value,Exchange,Exchange Order Id,Execution date and time,Order status\n"+
+			"Infosys,INFY,INE009A01021,BUY,4,5800,NSE,1100000012345,22-04-2024 10:31 AM,Executed\n"+
+			"Infosys,INFY,INE009A01021,SELL,4,6000,NSE,1100000012399,23-04-2024 11:02 AM,Cancelled\n")
+	writeTradeFile(t, dir, "upstox.csv",
+		"Date,Company,Amount,Exchange,Segment,Scrip Code,Instrument Type,Strike Price,Expiry,Trade Num,Trade Time,Side,Quantity,Price\n"+
+			"25-04-2024,INFOSYS LIMITED,2900,NSE,EQ,INFY,EQUITY,,,55012,10:15:31,Sell,2,1450\n")
+	writeTradeFile(t, dir, "zerodha.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			// the same trade id as the Upstox trade, trade ids of different brokers do not collide
+			"INFY,INE009A01021,2024-04-26,NSE,EQ,EQ,buy,false,1,1460,55012,101,2024-04-26T09:20:11\n")
+
+	tradebook := getTradebookService(t, dir, "")
+
+	trades := tradebook.GetEquityTradebook().EquityTradebook["INFY"]
+	require.Len(t, trades, 3)
+
+	assert.Equal(t, service.BrokerGroww, trades[0].Broker)
+	assert.Equal(t, time.Date(2024, 4, 22, 0, 0, 0, 0, time.UTC), trades[0].TradeDate)
+	assert.Equal(t, "buy", trades[0].TradeType)
+	assert.Equal(t, 4.0, trades[0].Quantity)
+	assert.Equal(t, 1450.0, trades[0].Price)
+	assert.Equal(t, "1100000012345", trades[0].TradeId)
+
+	assert.Equal(t, service.BrokerUpstox, trades[1].Broker)
+	assert.Equal(t, "sell", trades[1].TradeType)
+	assert.Equal(t, "55012", trades[1].TradeId)
+	assert.Empty(t, trades[1].Isin)
+
+	assert.Equal(t, service.BrokerZerodha, trades[2].Broker)
+
+	files := tradebook.GetIngestionReport().Equity.Files
+	require.Len(t, files, 3)
+	assert.Equal(t, service.BrokerGroww, files[0].Broker)
+	assert.Equal(t, 1, files[0].IgnoredRows)
+	assert.Equal(t, service.BrokerUpstox, files[1].Broker)
+	assert.Empty(t, tradebook.GetIngestionReport().Equity.Duplicates)
+}
+
+func TestTradebookGrowwFundOrders(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "groww.csv",
+		"Scheme Name,ISIN,Transaction Type,Units,NAV,Amount,Date\n"+
+			"ABC Flexi Cap Fund,INF000000001,PURCHASE,10.5,95.2,999.6,05-03-2024\n"+
+			"ABC Flexi Cap Fund,INF000000001,REDEEM,2,101,202,05-06-2024\n")
+
+	tradebook := getTradebookService(t, "", dir)
+
+	trades := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF000000001"]
+	require.Len(t, trades, 2)
+	assert.Equal(t, "buy", trades[0].TradeType)
+	assert.Equal(t, 95.2, trades[0].Price)
+	assert.Equal(t, "sell", trades[1].TradeType)
+	assert.Equal(t, service.BrokerGroww, trades[1].Broker)
+	assert.NotEqual(t, trades[0].TradeID, trades[1].TradeID)
+	assert.Equal(t, map[service.FundName]service.ISIN{"ABC Flexi Cap Fund": "INF000000001"}, tradebook.GetMutualFundsList())
+
+	t.Run("keeps identical orders of a day, dedupes them across exports", func(t *testing.T) {
+		dir := t.TempDir()
+		orders := "Scheme Name,ISIN,Transaction Type,Units,NAV,Amount,Date\n" +
+			"ABC Flexi Cap Fund,INF000000001,PURCHASE,10.5,95.2,999.6,05-03-2024\n" +
+			"ABC Flexi Cap Fund,INF000000001,PURCHASE,10.5,95.2,999.6,05-03-2024\n"
+		writeTradeFile(t, dir, "groww.csv", orders)
+		writeTradeFile(t, dir, "groww-again.csv", orders)
+
+		tradebook := getTradebookService(t, "", dir)
+
+		trades := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF000000001"]
+		require.Len(t, trades, 2)
+		assert.NotEqual(t, trades[0].TradeID, trades[1].TradeID)
+		report := tradebook.GetIngestionReport().MutualFunds
+		assert.Len(t, report.Duplicates, 2)
+	})
+}
+
+func TestTradebookCASStatements(t *testing.T) {
+	dir := t.TempDir()
+	writeTradeFile(t, dir, "coin.csv",
+		"symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n"+
+			"HDFC Flexi Cap Fund,INF179K01UT0,2023-04-03,BSE,MF,,buy,false,12.345,405.02,1,101,2023-04-03T09:20:11\n")
+	writeTradeFile(t, dir, "cas.txt", `Consolidated Account Statement
+01-Apr-2023 To 30-Jun-2023
+
+HDFC Mutual Fund
+Folio No: 1234567 / 89     PAN: ABCDE1234F
+HDFC Flexi Cap Fund - Direct Plan - Growth - ISIN: INF179K01UT0(Advisor: DIRECT) Registrar : CAMS
+Opening Unit Balance: 0.000
+04-Apr-2023   Purchase - via Zerodha                5,000.00     12.345     405.0200     12.345
+04-Apr-2023   *** Stamp Duty ***                        0.25
+05-May-2023   SIP Purchase - Instalment 1/12        1,000.00      2.400     416.6667     14.745
+10-Jun-2023   Redemption                           (2,000.00)    (4.600)    434.7826     10.145
+Closing Unit Balance: 10.145
+
+Axis Mutual Fund
+Folio No: 91011
+Axis ELSS Tax Saver Fund - Direct Growth - ISIN: INF846K01EW2(Advisor: DIRECT) Registrar : KFINTECH
+12-Jun-2023   Switch-In - From Axis Liquid Fund     3,000.00     35.100      85.4701     35.100
+`)
+	writeTradeFile(t, dir, "cas.csv",
+		"amc,folio,pan,scheme,isin,date,description,amount,units,nav,balance,type\n"+
+			"Axis Mutual Fund,91011,ABCDE1234F,Axis ELSS Tax Saver Fund,INF846K01EW2,2023-06-12,Switch-In - From Axis Liquid Fund,3000.00,35.100,85.4701,35.100,SWITCH_IN\n"+
+			"Axis Mutual Fund,91011,ABCDE1234F,Axis ELSS Tax Saver Fund,INF846K01EW2,2023-07-20,IDCW Reinvestment,150.00,1.700,88.2353,36.800,DIVIDEND_REINVEST\n"+
+			"Axis Mutual Fund,91011,ABCDE1234F,Axis ELSS Tax Saver Fund,INF846K01EW2,2023-07-20,IDCW Paid,200.00,,,36.800,DIVIDEND_PAYOUT\n")
+
+	tradebook := getTradebookService(t, "", dir)
+
+	hdfc := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF179K01UT0"]
+	require.Len(t, hdfc, 3)
+	// the purchase placed through Coin is read from the Coin tradebook only
+	assert.Equal(t, service.BrokerZerodha, hdfc[0].Broker)
+	assert.Equal(t, "sip", hdfc[1].Transaction)
+	assert.Equal(t, "1234567/89", hdfc[1].Folio)
+	assert.Equal(t, 1000.0, hdfc[1].Amount)
+	assert.Equal(t, "sell", hdfc[2].TradeType)
+	assert.Equal(t, "redemption", hdfc[2].Transaction)
+	assert.Equal(t, 4.6, hdfc[2].Quantity)
+	assert.Equal(t, 434.7826, hdfc[2].Price)
+
+	axis := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF846K01EW2"]
+	require.Len(t, axis, 2)
+	assert.Equal(t, "switch_in", axis[0].Transaction)
+	assert.Equal(t, "dividend_reinvest", axis[1].Transaction)
+	assert.Equal(t, service.FundName("HDFC Flexi Cap Fund"), tradebook.GetFundNameFromISIN("INF179K01UT0"))
+
+	report := tradebook.GetIngestionReport().MutualFunds
+	require.Len(t, report.Files, 3)
+	csvFile, textFile, coinFile := report.Files[0], report.Files[1], report.Files[2]
+	assert.Equal(t, service.BrokerCAS, csvFile.Broker)
+	assert.Equal(t, 2, csvFile.Rows)
+	assert.Zero(t, csvFile.Duplicates)
+	// the dividend payout
+	assert.Equal(t, 1, csvFile.IgnoredRows)
+	assert.Equal(t, 1, coinFile.Rows)
+	assert.Equal(t, 2, textFile.Rows)
+	// the switch in, also in the CSV statement, and the purchase, also in the Coin tradebook
+	assert.Equal(t, 2, textFile.Duplicates)
+	assert.Empty(t, textFile.Errors)
+	require.Len(t, report.Duplicates, 2)
+	assert.Equal(t, []string{csvFile.Name, textFile.Name}, report.Duplicates[0].Files)
+	assert.Equal(t, []string{coinFile.Name, textFile.Name}, report.Duplicates[1].Files)
+
+	t.Run("keeps identical transactions of a day in a folio", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTradeFile(t, dir, "cas.txt", `HDFC Mutual Fund
+Folio No: 1234567 / 89
+HDFC Flexi Cap Fund - Direct Plan - Growth - ISIN: INF179K01UT0(Advisor: DIRECT) Registrar : CAMS
+05-May-2023   SIP Purchase - Instalment 1/12        1,000.00      2.400     416.6667      2.400
+05-May-2023   SIP Purchase - Instalment 1/12        1,000.00      2.400     416.6667      4.800
+`)
+
+		tradebook := getTradebookService(t, "", dir)
+
+		trades := tradebook.GetMutualFundsTradebook().MutualFundsTradebook["INF179K01UT0"]
+		require.Len(t, trades, 2)
+		assert.NotEqual(t, trades[0].TradeID, trades[1].TradeID)
+	})
+}
+
+func TestTradebookAccounts(t *testing.T) {
+	selfDir, spouseDir := t.TempDir(), t.TempDir()
+	writeTradeFile(t, selfDir, "2023.csv",
+		"INFY,INE009A01021,2023-04-03,NSE,EQ,EQ,buy,false,10,1400.5,1,101,2023-04-03T09:20:11\n")
+	// trade ids are only unique within an account
+	writeTradeFile(t, spouseDir, "2023.csv",
+		"INFY,INE009A01021,2023-03-01,NSE,EQ,EQ,buy,false,4,1350,1,201,2023-03-01T11:00:00\n"+
+			"TCS,INE467B01029,2023-06-05,NSE,EQ,EQ,buy,false,2,3300,2,202,2023-06-05T10:00:00\n")
+
+	tradebook, err := service.GetTradebookService([]config.AccountConfig{
+		{Name: "self", Owner: "me", EquityTradeFilesDirectory: selfDir},
+		{Name: "spouse", Owner: "spouse", EquityTradeFilesDirectory: spouseDir},
+	}, slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, []service.AccountSummary{{Name: "self", Owner: "me"}, {Name: "spouse", Owner: "spouse"}}, tradebook.GetAccounts())
+
+	// the household holds the trades of both accounts in date order
+	assert.ElementsMatch(t, []service.ScriptName{"INFY", "TCS"}, tradebook.GetEquityList())
+	trades := tradebook.GetEquityTradebook().EquityTradebook["INFY"]
+	require.Len(t, trades, 2)
+	assert.Equal(t, "spouse", trades[0].Account)
+	assert.Equal(t, "self", trades[1].Account)
+
+	self, err := tradebook.Account("self")
+	require.NoError(t, err)
+	assert.Equal(t, []service.ScriptName{"INFY"}, self.GetEquityList())
+	breakdown, err := self.GetEqBreakdown("INFY")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, breakdown.NetQuantity)
+	assert.Equal(t, "self", self.GetTradebookStatus().Account)
+
+	household, err := tradebook.Account(service.AllAccounts)
+	require.NoError(t, err)
+	report := household.GetIngestionReport()
+	assert.Equal(t, 3, report.Equity.Trades)
+	require.Len(t, report.Accounts, 2)
+	assert.Equal(t, 1, report.Accounts[0].Equity.Trades)
+	assert.Equal(t, 2, report.Accounts[1].Equity.Trades)
+	assert.Empty(t, self.GetIngestionReport().Accounts)
+
+	_, err = tradebook.Account("parents")
+	assert.Error(t, err)
+
+	_, err = service.GetTradebookService([]config.AccountConfig{
+		{Name: "self", EquityTradeFilesDirectory: selfDir},
+		{Name: "self", EquityTradeFilesDirectory: spouseDir},
+	}, slog.Default())
+	assert.Error(t, err)
+}
+
+func TestMFSummaryXIRR(t *testing.T) {
+	mfDir := t.TempDir()
+	writeTradeFile(t, mfDir, "coin.csv",
+		"HDFC FLEXI CAP FUND,INF179K01UT0,2023-01-02,,,,buy,false,100,10,1,101,\n"+
+			"HDFC FLEXI CAP FUND,INF179K01UT0,2024-01-01,,,,buy,false,50,20,2,102,\n")
+	tradebook := getTradebookService(t, "", mfDir)
+
+	summary := tradebook.GetMFSummmary(time.Time{}, time.Now())
+	require.Len(t, summary, 1)
+
+	// the cash flows are the amounts traded, not the NAV of the trades
+	now := time.Now()
+	amounts := []service.MutualFundsTrade{
+		{TradeDate: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Price: 1000},
+		{TradeDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Price: 1000},
+		{TradeDate: now, Price: -3000},
+	}
+	navs := []service.MutualFundsTrade{amounts[0], amounts[1], amounts[2]}
+	navs[0].Price, navs[1].Price = 10, 20
+	assert.InDelta(t, utils.GetXIRR(amounts), summary[0].XIRR, 1e-3)
+	assert.NotEqual(t, utils.GetXIRR(navs), summary[0].XIRR)
+}
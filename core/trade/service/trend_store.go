@@ -0,0 +1,87 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// trendStore persists price history as one JSON array file per symbol
+type trendStore struct {
+	directory string
+}
+
+func (s trendStore) fileName(symbol string) string {
+	return path.Join(s.directory, symbol+".json")
+}
+
+func (s trendStore) read(symbol string, trend interface{}) error {
+	fileContent, err := os.ReadFile(s.fileName(symbol))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(fileContent, trend)
+}
+
+func (s trendStore) write(symbol string, trend interface{}) error {
+	fileContent, err := json.Marshal(trend)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal trend")
+	}
+	if _, err := os.Stat(s.directory); os.IsNotExist(err) {
+		if err := os.MkdirAll(s.directory, os.ModePerm); err != nil {
+			return errors.Wrapf(err, "unable to create trends directory %s", s.directory)
+		}
+	}
+	return os.WriteFile(s.fileName(symbol), fileContent, os.ModePerm)
+}
+
+// saveTrend persists merged, when it only adds points after the existing ones the
+// new points are appended to the file instead of rewriting the whole history
+func saveTrend[T utils.TimeGetter](s trendStore, symbol string, existing, merged []T, same func(a, b T) bool) error {
+	if len(existing) > 0 && isExtension(existing, merged, same) {
+		err := utils.AppendJSONArray(s.fileName(symbol), merged[len(existing):])
+		if err == nil {
+			return nil
+		}
+		// a missing or unreadable file falls back to a full rewrite
+	}
+	return s.write(symbol, merged)
+}
+
+func isExtension[T any](existing, merged []T, same func(a, b T) bool) bool {
+	if len(merged) < len(existing) {
+		return false
+	}
+	for i := range existing {
+		if !same(existing[i], merged[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeByDay combines two price series deduplicated by trading day,
+// incoming points win over existing ones for the same day
+func mergeByDay[T utils.TimeGetter](existing, incoming []T) []T {
+	byDay := make(map[string]T, len(existing)+len(incoming))
+	for _, v := range existing {
+		byDay[utils.DateKey(v.GetTime())] = v
+	}
+	for _, v := range incoming {
+		byDay[utils.DateKey(v.GetTime())] = v
+	}
+
+	merged := make([]T, 0, len(byDay))
+	for _, v := range byDay {
+		merged = append(merged, v)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].GetTime().Before(merged[j].GetTime())
+	})
+	return merged
+}
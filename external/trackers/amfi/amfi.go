@@ -0,0 +1,195 @@
+package amfi
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+	"github.com/pkg/errors"
+)
+
+const (
+	navDateLayout = "02-Jan-2006"
+	separator     = ";"
+)
+
+// Provider serves mutual fund NAV history from AMFI NAV files kept on disk.
+// Both the daily NAVAll.txt and the historical NAV report share the same
+// semicolon separated layout, only the set of columns differs, so columns
+// are located by their header name.
+type Provider struct {
+	directory string
+
+	mu      sync.Mutex
+	tracked map[string]struct{}
+	history map[string][]models.MFPriceData
+	loaded  bool
+}
+
+// NewProvider creates a provider reading every file in directory. Only rows for
+// the given ISINs are kept in memory, an ISIN requested later is picked up by
+// re-reading the files.
+func NewProvider(directory string, isins ...string) *Provider {
+	p := &Provider{
+		directory: directory,
+		tracked:   make(map[string]struct{}),
+	}
+	for _, isin := range isins {
+		p.tracked[isin] = struct{}{}
+	}
+	return p
+}
+
+func (p *Provider) Name() string {
+	return "amfi"
+}
+
+func (p *Provider) GetEQHistory(_ string, _, _ time.Time) ([]models.EquityPriceData, error) {
+	return nil, trackers.ErrNotSupported
+}
+
+func (p *Provider) GetMFHistory(isin string, from, to time.Time) ([]models.MFPriceData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.tracked[isin]; !ok {
+		p.tracked[isin] = struct{}{}
+		p.loaded = false
+	}
+	if !p.loaded {
+		history, err := ReadNAVFiles(p.directory, p.tracked)
+		if err != nil {
+			return nil, err
+		}
+		p.history = history
+		p.loaded = true
+	}
+
+	var requestedRange []models.MFPriceData
+	for _, v := range p.history[isin] {
+		if v.Timestamps.Before(from) || v.Timestamps.After(to) {
+			continue
+		}
+		requestedRange = append(requestedRange, v)
+	}
+	return requestedRange, nil
+}
+
+// ReadNAVFiles parses every AMFI NAV file in directory and returns the NAV history
+// per ISIN sorted by date. When isins is not empty rows for other ISINs are dropped.
+func ReadNAVFiles(directory string, isins map[string]struct{}) (map[string][]models.MFPriceData, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read AMFI NAV directory")
+	}
+
+	byDate := make(map[string]map[time.Time]float32)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fileName := path.Join(directory, entry.Name())
+		if err := readNAVFile(fileName, isins, byDate); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse AMFI NAV file %s", fileName)
+		}
+	}
+
+	history := make(map[string][]models.MFPriceData, len(byDate))
+	for isin, navs := range byDate {
+		trend := make([]models.MFPriceData, 0, len(navs))
+		for date, nav := range navs {
+			trend = append(trend, models.MFPriceData{
+				Timestamps: date,
+				Price:      nav,
+			})
+		}
+		sort.Slice(trend, func(i, j int) bool {
+			return trend[i].Timestamps.Before(trend[j].Timestamps)
+		})
+		history[isin] = trend
+	}
+	return history, nil
+}
+
+// readNAVFile adds the NAVs of one file into byDate. Lines that are not data rows,
+// like AMC names and scheme category headings, are skipped.
+func readNAVFile(fileName string, isins map[string]struct{}, byDate map[string]map[time.Time]float32) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		isinColumns []int
+		navColumn   = -1
+		dateColumn  = -1
+		columnCount int
+	)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), separator)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if strings.EqualFold(strings.TrimSpace(fields[0]), "Scheme Code") {
+			isinColumns, navColumn, dateColumn = nil, -1, -1
+			for i, name := range fields {
+				name = strings.ToLower(strings.TrimSpace(name))
+				switch {
+				case strings.Contains(name, "isin"):
+					isinColumns = append(isinColumns, i)
+				case name == "net asset value":
+					navColumn = i
+				case name == "date":
+					dateColumn = i
+				}
+			}
+			if len(isinColumns) == 0 || navColumn < 0 || dateColumn < 0 {
+				return errors.Errorf("unrecognised header %q", scanner.Text())
+			}
+			columnCount = len(fields)
+			continue
+		}
+
+		if columnCount == 0 || len(fields) != columnCount {
+			continue
+		}
+
+		nav, err := strconv.ParseFloat(strings.TrimSpace(fields[navColumn]), 32)
+		if err != nil {
+			// schemes without a declared NAV are reported as N.A.
+			continue
+		}
+		date, err := time.Parse(navDateLayout, strings.TrimSpace(fields[dateColumn]))
+		if err != nil {
+			continue
+		}
+
+		for _, column := range isinColumns {
+			isin := strings.TrimSpace(fields[column])
+			if isin == "" || isin == "-" {
+				continue
+			}
+			if len(isins) > 0 {
+				if _, ok := isins[isin]; !ok {
+					continue
+				}
+			}
+			if _, ok := byDate[isin]; !ok {
+				byDate[isin] = make(map[time.Time]float32)
+			}
+			byDate[isin][date] = float32(nav)
+		}
+	}
+	return scanner.Err()
+}
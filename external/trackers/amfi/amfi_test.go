@@ -0,0 +1,81 @@
+package amfi_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers/amfi"
+)
+
+const navAll = `Scheme Code;ISIN Div Payout/ ISIN Growth;ISIN Div Reinvestment;Scheme Name;Net Asset Value;Date
+
+Open Ended Schemes(Equity Scheme - Flexi Cap Fund)
+
+HDFC Mutual Fund
+
+118955;INF179K01UT0;-;HDFC Flexi Cap Fund - Growth Option - Direct Plan;1890.123;18-Oct-2024
+118956;INF179K01UU8;INF179K01UV6;HDFC Flexi Cap Fund - IDCW Option - Direct Plan;N.A.;18-Oct-2024
+`
+
+const navHistory = `Scheme Code;Scheme Name;ISIN Div Payout/ISIN Growth;ISIN Div Reinvestment;Net Asset Value;Repurchase Price;Sale Price;Date
+
+Open Ended Schemes ( Equity Scheme - Flexi Cap Fund )
+
+HDFC Mutual Fund
+
+118955;HDFC Flexi Cap Fund - Growth Option - Direct Plan;INF179K01UT0;;1885.5;;;17-Oct-2024
+118955;HDFC Flexi Cap Fund - Growth Option - Direct Plan;INF179K01UT0;;1870.25;;;16-Oct-2024
+120503;Some Other Fund - Direct Plan;INF000000000;;10.5;;;16-Oct-2024
+`
+
+func writeNAVFiles(t *testing.T) string {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(path.Join(dir, "NAVAll.txt"), []byte(navAll), 0o600))
+	assert.Nil(t, os.WriteFile(path.Join(dir, "history.txt"), []byte(navHistory), 0o600))
+	return dir
+}
+
+func TestReadNAVFiles(t *testing.T) {
+	t.Run("merges daily and historical files by header name", func(t *testing.T) {
+		dir := writeNAVFiles(t)
+
+		history, err := amfi.ReadNAVFiles(dir, map[string]struct{}{"INF179K01UT0": {}})
+		assert.Nil(t, err)
+		assert.Len(t, history, 1)
+
+		trend := history["INF179K01UT0"]
+		assert.Len(t, trend, 3)
+		assert.Equal(t, time.Date(2024, 10, 16, 0, 0, 0, 0, time.UTC), trend[0].Timestamps)
+		assert.Equal(t, float32(1870.25), trend[0].Price)
+		assert.Equal(t, float32(1890.123), trend[2].Price)
+	})
+
+	t.Run("skips schemes without a NAV", func(t *testing.T) {
+		dir := writeNAVFiles(t)
+
+		history, err := amfi.ReadNAVFiles(dir, nil)
+		assert.Nil(t, err)
+		assert.NotContains(t, history, "INF179K01UU8")
+		assert.Contains(t, history, "INF000000000")
+	})
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("serves history for ISINs requested after construction", func(t *testing.T) {
+		dir := writeNAVFiles(t)
+		p := amfi.NewProvider(dir, "INF179K01UT0")
+
+		from := time.Date(2024, 10, 17, 0, 0, 0, 0, time.UTC)
+		trend, err := p.GetMFHistory("INF179K01UT0", from, time.Now())
+		assert.Nil(t, err)
+		assert.Len(t, trend, 2)
+
+		trend, err = p.GetMFHistory("INF000000000", time.Time{}, time.Now())
+		assert.Nil(t, err)
+		assert.Len(t, trend, 1)
+	})
+}
@@ -0,0 +1,288 @@
+package bhavcopy
+
+import (
+	"encoding/csv"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+	"github.com/pkg/errors"
+)
+
+// column aliases across the NSE CM bhavcopy, the BSE equity bhavcopy
+// and the common UDiFF layout both exchanges moved to in 2024
+var (
+	symbolColumns = []string{"SYMBOL", "TCKRSYMB"}
+	isinColumns   = []string{"ISIN", "ISIN_CODE"}
+	seriesColumns = []string{"SERIES", "SCTYSRS"}
+	sourceColumns = []string{"SRC"}
+	typeColumns   = []string{"FININSTRMTP"}
+	dateColumns   = []string{"TIMESTAMP", "TRADDT", "TRADING_DATE"}
+	openColumns   = []string{"OPEN", "OPNPRIC"}
+	highColumns   = []string{"HIGH", "HGHPRIC"}
+	lowColumns    = []string{"LOW", "LWPRIC"}
+	closeColumns  = []string{"CLOSE", "CLSPRIC"}
+	volumeColumns = []string{"TOTTRDQTY", "TTLTRADGVOL", "NO_OF_SHRS"}
+
+	dateLayouts = []string{"2006-01-02", "02-Jan-2006", "02-Jan-06", "02/01/2006", "20060102"}
+
+	// BSE bhavcopies carry the trading day only in the file name, e.g. EQ_ISINCODE_180124.CSV
+	fileNameDate = regexp.MustCompile(`(\d{6})`)
+
+	// NSE series that represent equity shares, everything else is bonds, ETFs on other segments etc.
+	equitySeries = map[string]struct{}{"EQ": {}, "BE": {}, "BZ": {}, "SM": {}, "ST": {}}
+)
+
+// Bhavcopies is the candle history read from a directory of daily bhavcopy files
+type Bhavcopies struct {
+	// keyed by ISIN where the file has one, by symbol otherwise
+	candles    map[string]map[string]models.EquityPriceData
+	symbolISIN map[string]string
+}
+
+// ReadBhavcopies parses every CSV in directory, when two files have a candle
+// for the same instrument and day the one with the higher traded volume is kept.
+// A file that cannot be parsed is logged and skipped.
+func ReadBhavcopies(directory string, logger *slog.Logger) (*Bhavcopies, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read bhavcopy directory")
+	}
+
+	b := &Bhavcopies{
+		candles:    make(map[string]map[string]models.EquityPriceData),
+		symbolISIN: make(map[string]string),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(path.Ext(entry.Name()), ".csv") {
+			continue
+		}
+		fileName := path.Join(directory, entry.Name())
+		if err := b.readFile(fileName); err != nil {
+			logger.Warn("skipped bhavcopy", slog.String("file", fileName), slog.String("error", err.Error()))
+		}
+	}
+	return b, nil
+}
+
+func (b *Bhavcopies) readFile(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	header := make(map[string]int)
+	for i, name := range records[0] {
+		header[strings.ToUpper(strings.TrimSpace(name))] = i
+	}
+	column := func(aliases []string) int {
+		for _, alias := range aliases {
+			if i, ok := header[alias]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	symbolCol, isinCol := column(symbolColumns), column(isinColumns)
+	seriesCol, sourceCol, typeCol := column(seriesColumns), column(sourceColumns), column(typeColumns)
+	dateCol := column(dateColumns)
+	openCol, highCol, lowCol, closeCol := column(openColumns), column(highColumns), column(lowColumns), column(closeColumns)
+	volumeCol := column(volumeColumns)
+
+	if (symbolCol < 0 && isinCol < 0) || closeCol < 0 {
+		return errors.New("not a recognised bhavcopy, missing symbol/ISIN or close column")
+	}
+
+	var fileDate time.Time
+	if dateCol < 0 {
+		match := fileNameDate.FindString(path.Base(fileName))
+		fileDate, err = time.ParseInLocation("020106", match, utils.IST)
+		if err != nil {
+			return errors.New("no trading date column and no date in file name")
+		}
+	}
+
+	field := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	price := func(record []string, i int) float32 {
+		v, _ := strconv.ParseFloat(field(record, i), 32)
+		return float32(v)
+	}
+
+	for _, record := range records[1:] {
+		if t := field(record, typeCol); t != "" && t != "STK" {
+			continue
+		}
+		if field(record, sourceCol) != "BSE" && seriesCol >= 0 {
+			if _, ok := equitySeries[field(record, seriesCol)]; !ok {
+				continue
+			}
+		}
+
+		date := fileDate
+		if dateCol >= 0 {
+			date, err = parseDate(field(record, dateCol))
+			if err != nil {
+				continue
+			}
+		}
+
+		symbol := strings.ToUpper(field(record, symbolCol))
+		isin := field(record, isinCol)
+		key := isin
+		if key == "" {
+			key = symbol
+		}
+		if key == "" {
+			continue
+		}
+		if symbol != "" && isin != "" {
+			b.symbolISIN[symbol] = isin
+		}
+
+		candle := models.EquityPriceData{
+			Timestamps: date,
+			Open:       price(record, openCol),
+			High:       price(record, highCol),
+			Low:        price(record, lowCol),
+			Close:      price(record, closeCol),
+			Volume:     price(record, volumeCol),
+		}
+		if candle.Close == 0 {
+			continue
+		}
+
+		if _, ok := b.candles[key]; !ok {
+			b.candles[key] = make(map[string]models.EquityPriceData)
+		}
+		day := utils.DateKey(date)
+		if existing, ok := b.candles[key][day]; ok && existing.Volume >= candle.Volume {
+			continue
+		}
+		b.candles[key][day] = candle
+	}
+	return nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, utils.IST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("unrecognised date %q", value)
+}
+
+// History returns the candles of a symbol between from and to, sorted by date
+func (b *Bhavcopies) History(symbol string, from, to time.Time) []models.EquityPriceData {
+	symbol = strings.ToUpper(symbol)
+	days := make(map[string]models.EquityPriceData)
+	for k, v := range b.candles[symbol] {
+		days[k] = v
+	}
+	if isin, ok := b.symbolISIN[symbol]; ok {
+		for k, v := range b.candles[isin] {
+			if existing, ok := days[k]; ok && existing.Volume >= v.Volume {
+				continue
+			}
+			days[k] = v
+		}
+	}
+
+	history := make([]models.EquityPriceData, 0, len(days))
+	for _, v := range days {
+		if v.Timestamps.Before(from) || v.Timestamps.After(to) {
+			continue
+		}
+		history = append(history, v)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Timestamps.Before(history[j].Timestamps)
+	})
+	return history
+}
+
+// Provider serves equity candles from bhavcopies archived on disk, the directory is read
+// on first use and read again when its files change or reading it failed
+type Provider struct {
+	directory string
+	logger    *slog.Logger
+
+	mu         sync.Mutex
+	bhavcopies *Bhavcopies
+	// files is the name, size and modification time of the files bhavcopies was read from
+	files string
+}
+
+func NewProvider(directory string, logger *slog.Logger) *Provider {
+	return &Provider{directory: directory, logger: logger}
+}
+
+func (p *Provider) Name() string {
+	return "bhavcopy"
+}
+
+func (p *Provider) GetEQHistory(symbol string, from, to time.Time) ([]models.EquityPriceData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	files, err := listFiles(p.directory)
+	if err != nil {
+		return nil, err
+	}
+	if p.bhavcopies == nil || files != p.files {
+		bhavcopies, err := ReadBhavcopies(p.directory, p.logger)
+		if err != nil {
+			return nil, err
+		}
+		p.bhavcopies, p.files = bhavcopies, files
+	}
+	return p.bhavcopies.History(symbol, from, to), nil
+}
+
+// listFiles describes the files of directory, it changes when a file is added, removed or written
+func listFiles(directory string) (string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read bhavcopy directory")
+	}
+	var files strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files.WriteString(entry.Name() + "|" + strconv.FormatInt(info.Size(), 10) + "|" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n")
+	}
+	return files.String(), nil
+}
+
+func (p *Provider) GetMFHistory(_ string, _, _ time.Time) ([]models.MFPriceData, error) {
+	return nil, trackers.ErrNotSupported
+}
@@ -0,0 +1,73 @@
+package bhavcopy_test
+
+import (
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers/bhavcopy"
+	"github.com/Mryashbhardwaj/marketAnalysis/internal/utils"
+)
+
+const nseLegacy = `SYMBOL,SERIES,OPEN,HIGH,LOW,CLOSE,LAST,PREVCLOSE,TOTTRDQTY,TOTTRDVAL,TIMESTAMP,TOTALTRADES,ISIN,
+INFY,EQ,1500,1520,1490,1510,1511,1495,100000,151000000,01-JAN-2024,5000,INE009A01021,
+INFY,N1,100,100,100,100,100,100,10,1000,01-JAN-2024,1,INE009A08021,
+`
+
+const udiff = `TradDt,BizDt,Sgmt,Src,FinInstrmTp,FinInstrmId,ISIN,TckrSymb,SctySrs,OpnPric,HghPric,LwPric,ClsPric,TtlTradgVol
+2024-01-02,2024-01-02,CM,NSE,STK,1594,INE009A01021,INFY,EQ,1510,1530,1500,1525,120000
+`
+
+const bseLegacy = `SC_CODE,SC_NAME,SC_GROUP,SC_TYPE,OPEN,HIGH,LOW,CLOSE,LAST,PREVCLOSE,NO_TRADES,NO_OF_SHRS,NET_TURNOV,TDCLOINDI,ISIN_CODE
+500209,INFOSYS LTD,A ,Q,1508,1528,1498,1524,1524,1510,900,20000,30000000,,INE009A01021
+500210,OTHER LTD,A ,Q,10,10,10,10,10,10,9,200,2000,,INE000A01000
+`
+
+func TestReadBhavcopies(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(path.Join(dir, "cm01JAN2024bhav.csv"), []byte(nseLegacy), 0o600))
+	assert.Nil(t, os.WriteFile(path.Join(dir, "BhavCopy_NSE_CM_20240102.csv"), []byte(udiff), 0o600))
+	assert.Nil(t, os.WriteFile(path.Join(dir, "EQ_ISINCODE_020124.CSV"), []byte(bseLegacy), 0o600))
+
+	b, err := bhavcopy.ReadBhavcopies(dir, slog.Default())
+	assert.Nil(t, err)
+
+	history := b.History("infy", time.Time{}, time.Now())
+	assert.Len(t, history, 2)
+
+	assert.Equal(t, "2024-01-01", utils.DateKey(history[0].Timestamps))
+	assert.Equal(t, float32(1510), history[0].Close)
+
+	// NSE and BSE candles of the same day are deduplicated, the more liquid one is kept
+	assert.Equal(t, "2024-01-02", utils.DateKey(history[1].Timestamps))
+	assert.Equal(t, float32(1525), history[1].Close)
+	assert.Equal(t, float32(120000), history[1].Volume)
+}
+
+func TestBhavcopyProvider(t *testing.T) {
+	dir := path.Join(t.TempDir(), "bhavcopies")
+	p := bhavcopy.NewProvider(dir, slog.Default())
+
+	// the directory is read again once it exists
+	_, err := p.GetEQHistory("INFY", time.Time{}, time.Now())
+	assert.Error(t, err)
+
+	assert.Nil(t, os.Mkdir(dir, 0o700))
+	assert.Nil(t, os.WriteFile(path.Join(dir, "cm01JAN2024bhav.csv"), []byte(nseLegacy), 0o600))
+	assert.Nil(t, os.WriteFile(path.Join(dir, "notes.csv"), []byte("a,b\n1,2\n"), 0o600))
+
+	// an unrecognised file is skipped
+	history, err := p.GetEQHistory("INFY", time.Time{}, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, history, 1)
+
+	// a file added later is picked up
+	assert.Nil(t, os.WriteFile(path.Join(dir, "BhavCopy_NSE_CM_20240102.csv"), []byte(udiff), 0o600))
+	history, err = p.GetEQHistory("INFY", time.Time{}, time.Now())
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+}
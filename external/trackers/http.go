@@ -0,0 +1,136 @@
+package trackers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRequestsPerSecond = 2
+	defaultBackoff           = 500 * time.Millisecond
+)
+
+// StatusError is returned for a non 200 upstream response
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s", e.StatusCode, e.URL)
+}
+
+// Retryable reports whether the request may succeed when tried again
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// HTTPClient is shared by the providers calling public APIs. It limits the request
+// rate per host and retries rate limited and failed requests with exponential backoff.
+type HTTPClient struct {
+	client     *http.Client
+	interval   time.Duration
+	maxRetries int
+	backoff    time.Duration
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+}
+
+// NewHTTPClient creates a client allowing requestsPerSecond per host
+// and retrying a failed request up to maxRetries times
+func NewHTTPClient(requestsPerSecond float64, maxRetries int, logger *slog.Logger) *HTTPClient {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = defaultRequestsPerSecond
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &HTTPClient{
+		client:     &http.Client{Timeout: time.Minute},
+		interval:   time.Duration(float64(time.Second) / requestsPerSecond),
+		maxRetries: maxRetries,
+		backoff:    defaultBackoff,
+		logger:     logger,
+		nextSlot:   make(map[string]time.Time),
+	}
+}
+
+// Get fetches rawURL and returns the response body
+func (c *HTTPClient) Get(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		c.wait(u.Host)
+		body, retryAfter, err := c.get(rawURL)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		statusErr, ok := err.(*StatusError)
+		if !ok || !statusErr.Retryable() || attempt == c.maxRetries {
+			break
+		}
+		delay := c.backoff << attempt
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		time.Sleep(delay)
+	}
+	return nil, lastErr
+}
+
+func (c *HTTPClient) get(rawURL string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			c.logger.Warn("failed to close response body", slog.String("url", rawURL), slog.String("error", cerr.Error()))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, retryAfter, &StatusError{URL: rawURL, StatusCode: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	return body, 0, err
+}
+
+// wait blocks until the next request slot for host, slots are handed out
+// one interval apart so concurrent callers are spread evenly
+func (c *HTTPClient) wait(host string) {
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextSlot[host] = slot.Add(c.interval)
+	c.mu.Unlock()
+
+	time.Sleep(time.Until(slot))
+}
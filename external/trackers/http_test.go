@@ -0,0 +1,66 @@
+package trackers_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+)
+
+func TestHTTPClient(t *testing.T) {
+	t.Run("retries rate limited and failed requests", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			switch calls.Add(1) {
+			case 1:
+				w.WriteHeader(http.StatusTooManyRequests)
+			case 2:
+				w.WriteHeader(http.StatusBadGateway)
+			default:
+				_, _ = w.Write([]byte("ok"))
+			}
+		}))
+		defer server.Close()
+
+		body, err := trackers.NewHTTPClient(100, 3, slog.Default()).Get(server.URL)
+		assert.Nil(t, err)
+		assert.Equal(t, "ok", string(body))
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		_, err := trackers.NewHTTPClient(100, 3, slog.Default()).Get(server.URL)
+		statusErr, ok := err.(*trackers.StatusError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("spaces requests to the same host", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		client := trackers.NewHTTPClient(20, 0, slog.Default())
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := client.Get(server.URL)
+			assert.Nil(t, err)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+}
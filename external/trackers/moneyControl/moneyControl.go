@@ -3,39 +3,43 @@ package moneycontrol
 import (
 	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"math"
-	"net/http"
 	"time"
 
 	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
 )
 
-func GetMFHistoryFromMoneyControll(isin string) ([]models.MFPriceData, error) {
-	priceAPIURL := fmt.Sprintf("https://www.moneycontrol.com/mc/widget/mfnavonetimeinvestment/get_chart_value?isin=%s&dur=ALL", isin)
-	fmt.Println(priceAPIURL)
-
-	req, err := http.NewRequest("GET", priceAPIURL, nil)
-	if err != nil {
-		return nil, err
-	}
+// navDurations are the chart windows the NAV endpoint serves, smallest first
+var navDurations = []struct {
+	dur    string
+	window time.Duration
+}{
+	{"1M", 30 * 24 * time.Hour},
+	{"3M", 91 * 24 * time.Hour},
+	{"6M", 182 * 24 * time.Hour},
+	{"1Y", 365 * 24 * time.Hour},
+	{"3Y", 3 * 365 * 24 * time.Hour},
+	{"5Y", 5 * 365 * 24 * time.Hour},
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// navDuration picks the smallest chart window covering everything since from
+func navDuration(from time.Time) string {
+	// leave a few days of slack for holidays at the edge of the window
+	since := time.Since(from) + 7*24*time.Hour
+	for _, d := range navDurations {
+		if since <= d.window {
+			return d.dur
+		}
 	}
+	return "ALL"
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
+func (p *Provider) GetMFHistoryFromMoneyControll(isin string, from time.Time) ([]models.MFPriceData, error) {
+	priceAPIURL := fmt.Sprintf("https://www.moneycontrol.com/mc/widget/mfnavonetimeinvestment/get_chart_value?isin=%s&dur=%s", isin, navDuration(from))
+	p.logger.Debug("fetching price history", slog.String("url", priceAPIURL))
 
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("error closing response body: %v\n", cerr)
-		}
-	}()
-	body, err := io.ReadAll(resp.Body)
+	body, err := p.client.Get(priceAPIURL)
 	if err != nil {
 		return nil, err
 	}
@@ -53,34 +57,12 @@ func GetMFHistoryFromMoneyControll(isin string) ([]models.MFPriceData, error) {
 	return priceHistory, err
 }
 
-func GetEQHistoryFromMoneyControll(tickerSymbol string) (*models.MoneyControlResponse, error) {
-	startTime := time.Unix(490147200, 0)
-	endTime := time.Now()
+func (p *Provider) GetEQHistoryFromMoneyControll(tickerSymbol string, startTime, endTime time.Time) (*models.MoneyControlResponse, error) {
 	durationSince := math.Ceil(endTime.Sub(startTime).Hours() / 24)
 	priceAPIURL := fmt.Sprintf("https://priceapi.moneycontrol.com/techCharts/indianMarket/stock/history?symbol=%s&resolution=1D&from=%d&to=%d&countback=%.f&currencyCode=INR", tickerSymbol, startTime.Unix(), endTime.Unix(), durationSince)
-	fmt.Println(priceAPIURL)
+	p.logger.Debug("fetching price history", slog.String("url", priceAPIURL))
 
-	req, err := http.NewRequest("GET", priceAPIURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
-
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil {
-			fmt.Printf("error closing response body: %v\n", cerr)
-		}
-	}()
-	body, err := io.ReadAll(resp.Body)
+	body, err := p.client.Get(priceAPIURL)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,60 @@
+package moneycontrol
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+)
+
+// Provider serves equity and mutual fund history from the MoneyControl APIs
+type Provider struct {
+	client *trackers.HTTPClient
+	logger *slog.Logger
+}
+
+func NewProvider(client *trackers.HTTPClient, logger *slog.Logger) *Provider {
+	return &Provider{client: client, logger: logger}
+}
+
+func (p *Provider) Name() string {
+	return "moneycontrol"
+}
+
+func (p *Provider) GetEQHistory(symbol string, from, to time.Time) ([]models.EquityPriceData, error) {
+	k, err := p.GetEQHistoryFromMoneyControll(symbol, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	candlePoints := make([]models.EquityPriceData, len(k.T))
+	for i, timeStamp := range k.T {
+		candlePoints[i] = models.EquityPriceData{
+			Close:      k.C[i],
+			High:       k.H[i],
+			Volume:     k.V[i],
+			Open:       k.O[i],
+			Low:        k.L[i],
+			Timestamps: time.Unix(timeStamp, 0),
+		}
+	}
+	return candlePoints, nil
+}
+
+func (p *Provider) GetMFHistory(isin string, from, to time.Time) ([]models.MFPriceData, error) {
+	history, err := p.GetMFHistoryFromMoneyControll(isin, from)
+	if err != nil {
+		return nil, err
+	}
+
+	// the NAV endpoint serves fixed chart windows, trim it to the requested range
+	var requestedRange []models.MFPriceData
+	for _, v := range history {
+		if v.Timestamps.Before(from) || v.Timestamps.After(to) {
+			continue
+		}
+		requestedRange = append(requestedRange, v)
+	}
+	return requestedRange, nil
+}
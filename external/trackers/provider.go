@@ -0,0 +1,156 @@
+package trackers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotSupported is returned by a provider that does not serve the requested asset class
+	ErrNotSupported = errors.New("not supported by provider")
+	// ErrNoData is returned when a provider has no price points for the requested symbol
+	ErrNoData = errors.New("no price data")
+)
+
+// PriceProvider is an upstream source of historical prices.
+// Equity history is daily OHLCV candles keyed by ticker symbol,
+// mutual fund history is daily NAV keyed by ISIN.
+type PriceProvider interface {
+	Name() string
+	GetEQHistory(symbol string, from, to time.Time) ([]models.EquityPriceData, error)
+	GetMFHistory(isin string, from, to time.Time) ([]models.MFPriceData, error)
+}
+
+// Registry holds the known price providers by name
+type Registry struct {
+	providers map[string]PriceProvider
+	order     []string
+}
+
+func NewRegistry(providers ...PriceProvider) *Registry {
+	r := &Registry{
+		providers: make(map[string]PriceProvider),
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a provider, replacing any provider already registered with the same name
+func (r *Registry) Register(p PriceProvider) {
+	name := strings.ToLower(p.Name())
+	if _, ok := r.providers[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.providers[name] = p
+}
+
+func (r *Registry) Get(name string) (PriceProvider, bool) {
+	p, ok := r.providers[strings.ToLower(name)]
+	return p, ok
+}
+
+// Chain returns a provider that tries the named providers in order, falling back
+// to the next one when a provider fails. With no names every registered provider
+// is used in registration order.
+func (r *Registry) Chain(names []string) (*Chain, error) {
+	if len(names) == 0 {
+		names = r.order
+	}
+	chain := &Chain{}
+	for _, name := range names {
+		p, ok := r.Get(name)
+		if !ok {
+			return nil, errors.Errorf("unknown price provider %q", name)
+		}
+		chain.providers = append(chain.providers, p)
+	}
+	if len(chain.providers) == 0 {
+		return nil, errors.New("no price providers configured")
+	}
+	return chain, nil
+}
+
+// Chain is a PriceProvider with automatic fallback across providers
+type Chain struct {
+	providers []PriceProvider
+}
+
+func NewChain(providers ...PriceProvider) *Chain {
+	return &Chain{providers: providers}
+}
+
+func (c *Chain) Name() string {
+	names := make([]string, len(c.providers))
+	for i, p := range c.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *Chain) GetEQHistory(symbol string, from, to time.Time) ([]models.EquityPriceData, error) {
+	var failures chainFailures
+	for _, p := range c.providers {
+		history, err := p.GetEQHistory(symbol, from, to)
+		if err == nil && len(history) == 0 {
+			err = ErrNoData
+		}
+		if err != nil {
+			failures.add(p, err)
+			continue
+		}
+		return history, nil
+	}
+	return nil, failures.err(symbol)
+}
+
+func (c *Chain) GetMFHistory(isin string, from, to time.Time) ([]models.MFPriceData, error) {
+	var failures chainFailures
+	for _, p := range c.providers {
+		history, err := p.GetMFHistory(isin, from, to)
+		if err == nil && len(history) == 0 {
+			err = ErrNoData
+		}
+		if err != nil {
+			failures.add(p, err)
+			continue
+		}
+		return history, nil
+	}
+	return nil, failures.err(isin)
+}
+
+type chainFailures struct {
+	errorList []string
+	// onlyNoData stays true while every provider either had no data or does not serve the asset class
+	onlyNoData bool
+	noData     bool
+	seen       bool
+}
+
+func (f *chainFailures) add(p PriceProvider, err error) {
+	if !f.seen {
+		f.onlyNoData = true
+		f.seen = true
+	}
+	if errors.Is(err, ErrNoData) {
+		f.noData = true
+	} else if !errors.Is(err, ErrNotSupported) {
+		f.onlyNoData = false
+	}
+	f.errorList = append(f.errorList, fmt.Sprintf("%s: %s", p.Name(), err.Error()))
+}
+
+// err keeps ErrNoData matchable when no provider actually failed,
+// so callers can tell an up to date symbol from a broken upstream
+func (f *chainFailures) err(symbol string) error {
+	if f.onlyNoData && f.noData {
+		return errors.Wrapf(ErrNoData, "no provider has data for %s: %s", symbol, strings.Join(f.errorList, "; "))
+	}
+	return errors.Errorf("all providers failed for %s: %s", symbol, strings.Join(f.errorList, "; "))
+}
@@ -0,0 +1,90 @@
+package trackers_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Mryashbhardwaj/marketAnalysis/core/trade/models"
+	"github.com/Mryashbhardwaj/marketAnalysis/external/trackers"
+)
+
+type fakeProvider struct {
+	name   string
+	eq     []models.EquityPriceData
+	mf     []models.MFPriceData
+	err    error
+	called int
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) GetEQHistory(_ string, _, _ time.Time) ([]models.EquityPriceData, error) {
+	f.called++
+	return f.eq, f.err
+}
+
+func (f *fakeProvider) GetMFHistory(_ string, _, _ time.Time) ([]models.MFPriceData, error) {
+	f.called++
+	return f.mf, f.err
+}
+
+func TestChain(t *testing.T) {
+	now := time.Now()
+
+	t.Run("falls back to the next provider when one fails", func(t *testing.T) {
+		broken := &fakeProvider{name: "broken", err: errors.New("upstream down")}
+		backup := &fakeProvider{name: "backup", eq: []models.EquityPriceData{{Timestamps: now, Close: 10}}}
+
+		chain, err := trackers.NewRegistry(broken, backup).Chain([]string{"broken", "backup"})
+		assert.Nil(t, err)
+
+		history, err := chain.GetEQHistory("INFY", now, now)
+		assert.Nil(t, err)
+		assert.Len(t, history, 1)
+		assert.Equal(t, 1, broken.called)
+		assert.Equal(t, 1, backup.called)
+	})
+
+	t.Run("treats empty history as a failure", func(t *testing.T) {
+		empty := &fakeProvider{name: "empty"}
+		backup := &fakeProvider{name: "backup", mf: []models.MFPriceData{{Timestamps: now, Price: 10}}}
+
+		history, err := trackers.NewChain(empty, backup).GetMFHistory("INF179K01UT0", now, now)
+		assert.Nil(t, err)
+		assert.Len(t, history, 1)
+	})
+
+	t.Run("stops at the first provider that succeeds", func(t *testing.T) {
+		first := &fakeProvider{name: "first", eq: []models.EquityPriceData{{Timestamps: now}}}
+		second := &fakeProvider{name: "second", eq: []models.EquityPriceData{{Timestamps: now}}}
+
+		_, err := trackers.NewChain(first, second).GetEQHistory("INFY", now, now)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, second.called)
+	})
+
+	t.Run("returns every provider error when all fail", func(t *testing.T) {
+		a := &fakeProvider{name: "a", err: errors.New("boom")}
+		b := &fakeProvider{name: "b", err: trackers.ErrNotSupported}
+
+		_, err := trackers.NewChain(a, b).GetMFHistory("INF179K01UT0", now, now)
+		assert.ErrorContains(t, err, "a: boom")
+		assert.ErrorContains(t, err, "b: not supported by provider")
+	})
+
+	t.Run("rejects unknown provider names", func(t *testing.T) {
+		_, err := trackers.NewRegistry(&fakeProvider{name: "a"}).Chain([]string{"missing"})
+		assert.ErrorContains(t, err, "unknown price provider")
+	})
+
+	t.Run("uses every registered provider in order by default", func(t *testing.T) {
+		chain, err := trackers.NewRegistry(&fakeProvider{name: "a"}, &fakeProvider{name: "b"}).Chain(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a,b", chain.Name())
+	})
+}
@@ -1,8 +1,8 @@
 package utils
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"path"
 
 	"log"
@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -28,25 +27,6 @@ func ReadDir(dir string) ([]string, error) {
 	return tradeFilesStrings, nil
 }
 
-// skip header - flag - int - skip how many lines
-// change name to readandmergecsvfiles
-func ReadCSV(tradeFiles []string) ([][]string, error) {
-	var tradeFilesCombined [][]string
-	for _, tf := range tradeFiles {
-		file, err := os.ReadFile(tf)
-		if err != nil {
-			return nil, err
-		}
-		r := csv.NewReader(strings.NewReader(string(file)))
-		k, err := r.ReadAll()
-		if err != nil {
-			return nil, err
-		}
-		tradeFilesCombined = append(tradeFilesCombined, k...)
-	}
-	return tradeFilesCombined, nil
-}
-
 func GetTimeRange(r *http.Request) (time.Time, time.Time, error) {
 	fromStr := r.URL.Query().Get("from")
 	toStr := r.URL.Query().Get("to")
@@ -209,6 +189,82 @@ func absDuration(d time.Duration) time.Duration {
 	return d
 }
 
+// IST is the timezone Indian exchanges and fund houses publish prices in
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// DateKey returns the trading day of t in IST, used to dedupe daily price points
+func DateKey(t time.Time) string {
+	return t.In(IST).Format(time.DateOnly)
+}
+
+// FinancialYear returns the Indian financial year, April to March, of t as e.g. 2024-25
+func FinancialYear(t time.Time) string {
+	year := t.Year()
+	if t.Month() < time.April {
+		year--
+	}
+	return fmt.Sprintf("%d-%02d", year, (year+1)%100)
+}
+
+// AppendJSONArray appends items to the JSON array stored in fileName in place,
+// without reading or rewriting the elements already in the file
+func AppendJSONArray[T any](fileName string, items []T) error {
+	if len(items) == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(fileName, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	closing, err := lastNonSpace(file, info.Size()-1)
+	if err != nil || closing.char != ']' {
+		return errors.Errorf("%s does not hold a JSON array", fileName)
+	}
+	previous, err := lastNonSpace(file, closing.pos-1)
+	if err != nil {
+		return errors.Errorf("%s does not hold a JSON array", fileName)
+	}
+
+	content, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	// drop the opening bracket, the closing one of the new content ends the file
+	content = content[1:]
+	if previous.char != '[' {
+		content = append([]byte{','}, content...)
+	}
+	_, err = file.WriteAt(content, closing.pos)
+	return err
+}
+
+type filePosition struct {
+	pos  int64
+	char byte
+}
+
+func lastNonSpace(file *os.File, from int64) (filePosition, error) {
+	buf := make([]byte, 1)
+	for pos := from; pos >= 0; pos-- {
+		if _, err := file.ReadAt(buf, pos); err != nil {
+			return filePosition{}, err
+		}
+		switch buf[0] {
+		case ' ', '\n', '\r', '\t':
+			continue
+		}
+		return filePosition{pos: pos, char: buf[0]}, nil
+	}
+	return filePosition{}, errors.New("no content")
+}
+
 // // todo: only use this fucntion
 // func persistTrendInFile(fileName string, trend interface{}) error {
 // 	fileContent, err := json.Marshal(trend)
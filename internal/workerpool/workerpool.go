@@ -0,0 +1,32 @@
+package workerpool
+
+import "sync"
+
+// Run calls fn for every item using at most concurrency goroutines and
+// returns once every call has finished
+func Run[T any](items []T, concurrency int, fn func(T)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	queue := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				fn(item)
+			}
+		}()
+	}
+
+	for _, item := range items {
+		queue <- item
+	}
+	close(queue)
+	wg.Wait()
+}